@@ -0,0 +1,215 @@
+// Copyright 2016 The Gem Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file.
+
+package gem
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+)
+
+// Access log formats, any other format is treated as a text/template
+// which is executed with *AccessLogEntry, such as:
+//
+//	{{.Method}} {{.URI}} {{.Status}} {{.Latency}}
+const (
+	// CommonLogFormat is the Common Log Format:
+	//
+	//	127.0.0.1 - frank [10/Oct/2000:13:55:36 -0700] "GET /apache_pb.gif HTTP/1.0" 200 2326
+	CommonLogFormat = "common"
+
+	// CombinedLogFormat is the Combined Log Format, the referer and
+	// user agent are appended to the Common Log Format.
+	CombinedLogFormat = "combined"
+
+	// JSONLogFormat formats each entry as a line of JSON.
+	JSONLogFormat = "json"
+)
+
+// AccessLogEntry contains the information of a request.
+type AccessLogEntry struct {
+	Time       time.Time     `json:"time"`
+	RemoteAddr string        `json:"remote_addr"`
+	User       string        `json:"user,omitempty"`
+	Method     string        `json:"method"`
+	URI        string        `json:"uri"`
+	Path       string        `json:"path"`
+	Route      string        `json:"route,omitempty"`
+	RequestID  string        `json:"request_id,omitempty"`
+	Proto      string        `json:"proto"`
+	Status     int           `json:"status"`
+	Size       int64         `json:"size"`
+	Latency    time.Duration `json:"latency"` // in nanoseconds
+	Referer    string        `json:"referer,omitempty"`
+	UserAgent  string        `json:"user_agent,omitempty"`
+}
+
+// NewAccessLog returns an access log middleware with the given format,
+// the entries are written via the server's logger.
+func NewAccessLog(format string) *AccessLog {
+	return &AccessLog{Format: format}
+}
+
+// AccessLog is a middleware that logs the requests.
+type AccessLog struct {
+	// Format is one of CommonLogFormat, CombinedLogFormat, JSONLogFormat,
+	// or a text/template, defaults to CommonLogFormat.
+	Format string
+
+	// Output is the writer that the entries are written to, the entries
+	// are written via the server's logger at info level if nil.
+	Output io.Writer
+
+	// SkipPaths contains the paths that should not be logged, the path
+	// ending with '*' matches the paths that have the same prefix,
+	// such as "/assets/*".
+	SkipPaths []string
+
+	// Filter reports whether the entry should be logged, such as
+	// logging the failed requests only:
+	//
+	//	func(entry *gem.AccessLogEntry) bool {
+	//		return entry.Status >= 400
+	//	}
+	Filter func(entry *AccessLogEntry) bool
+
+	mu sync.Mutex
+}
+
+// Wrap implements the Middleware interface.
+//
+// It panics if the Format is an invalid template.
+func (l *AccessLog) Wrap(next Handler) Handler {
+	format, err := l.formatter()
+	if err != nil {
+		panic(err)
+	}
+
+	return HandlerFunc(func(ctx *Context) {
+		start := time.Now()
+		w := ctx.ResponseWriter()
+		size := w.Size()
+
+		next.Handle(ctx)
+
+		entry := newAccessLogEntry(ctx, start, w.Status(), w.Size()-size)
+		if !l.shouldLog(entry) {
+			return
+		}
+
+		l.write(ctx, format(entry))
+	})
+}
+
+func newAccessLogEntry(ctx *Context, start time.Time, status int, size int64) *AccessLogEntry {
+	r := ctx.Request
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	user, _, _ := r.BasicAuth()
+
+	if status == 0 {
+		status = http.StatusOK
+	}
+
+	return &AccessLogEntry{
+		Time:       start,
+		RemoteAddr: host,
+		User:       user,
+		Method:     r.Method,
+		URI:        r.RequestURI,
+		Path:       r.URL.Path,
+		Route:      ctx.route,
+		RequestID:  ctx.RequestID(),
+		Proto:      r.Proto,
+		Status:     status,
+		Size:       size,
+		Latency:    time.Since(start),
+		Referer:    r.Referer(),
+		UserAgent:  r.UserAgent(),
+	}
+}
+
+func (l *AccessLog) shouldLog(entry *AccessLogEntry) bool {
+	for _, path := range l.SkipPaths {
+		if strings.HasSuffix(path, "*") {
+			if strings.HasPrefix(entry.Path, path[:len(path)-1]) {
+				return false
+			}
+		} else if entry.Path == path {
+			return false
+		}
+	}
+
+	return l.Filter == nil || l.Filter(entry)
+}
+
+func (l *AccessLog) write(ctx *Context, line string) {
+	if l.Output == nil {
+		ctx.serverLogger().Info(line)
+		return
+	}
+
+	l.mu.Lock()
+	io.WriteString(l.Output, line+"\n")
+	l.mu.Unlock()
+}
+
+func (l *AccessLog) formatter() (func(*AccessLogEntry) string, error) {
+	switch l.Format {
+	case "", CommonLogFormat:
+		return formatCommonLog, nil
+	case CombinedLogFormat:
+		return func(entry *AccessLogEntry) string {
+			return formatCommonLog(entry) + " " + strconv.Quote(entry.Referer) + " " + strconv.Quote(entry.UserAgent)
+		}, nil
+	case JSONLogFormat:
+		return func(entry *AccessLogEntry) string {
+			data, _ := json.Marshal(entry)
+			return string(data)
+		}, nil
+	}
+
+	tmpl, err := template.New("access_log").Parse(l.Format)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(entry *AccessLogEntry) string {
+		buf := &bytes.Buffer{}
+		if err := tmpl.Execute(buf, entry); err != nil {
+			return "access log error: " + err.Error()
+		}
+		return buf.String()
+	}, nil
+}
+
+func formatCommonLog(entry *AccessLogEntry) string {
+	size := "-"
+	if entry.Size > 0 {
+		size = strconv.FormatInt(entry.Size, 10)
+	}
+
+	return dashIfEmpty(entry.RemoteAddr) + " - " + dashIfEmpty(entry.User) +
+		" [" + entry.Time.Format("02/Jan/2006:15:04:05 -0700") + "] " +
+		strconv.Quote(entry.Method+" "+entry.URI+" "+entry.Proto) + " " +
+		strconv.Itoa(entry.Status) + " " + size
+}
+
+func dashIfEmpty(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
@@ -0,0 +1,118 @@
+// Copyright 2016 The Gem Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file.
+
+package gem
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/go-gem/log"
+)
+
+func serveAccessLog(l *AccessLog, srv *Server, method, target string) *httptest.ResponseRecorder {
+	router := NewRouter()
+	router.Use(l)
+	router.GET("/users/:name", func(ctx *Context) {
+		ctx.Response.WriteHeader(http.StatusCreated)
+		ctx.Response.Write([]byte("hello"))
+	})
+	router.GET("/empty", func(ctx *Context) {})
+
+	req := httptest.NewRequest(method, target, nil)
+	req.RemoteAddr = "127.0.0.1:12345"
+	req.SetBasicAuth("frank", "secret")
+	req.Header.Set("Referer", "http://example.com/")
+	req.Header.Set("User-Agent", "gem-test")
+	w := httptest.NewRecorder()
+	router.Handler().Handle(newContext(srv, w, req))
+	return w
+}
+
+func TestAccessLog(t *testing.T) {
+	tests := []struct {
+		format string
+		target string
+		want   string
+	}{
+		{CommonLogFormat, "/users/foo?page=1", `^127\.0\.0\.1 - frank \[\d{2}/\w{3}/\d{4}:\d{2}:\d{2}:\d{2} [+-]\d{4}\] "GET /users/foo\?page=1 HTTP/1\.1" 201 5\n$`},
+		{"", "/empty", `^127\.0\.0\.1 - frank \[.+\] "GET /empty HTTP/1\.1" 200 -\n$`},
+		{CombinedLogFormat, "/users/foo", `^127\.0\.0\.1 - frank \[.+\] "GET /users/foo HTTP/1\.1" 201 5 "http://example\.com/" "gem-test"\n$`},
+		{"{{.Method}} {{.Route}} {{.Status}} {{.Size}}", "/users/foo", "^GET /users/:name 201 5\n$"},
+		{"{{.Method}} {{.Route}} {{.Status}}", "/nope", "^GET  404\n$"},
+	}
+	for _, test := range tests {
+		buf := &bytes.Buffer{}
+		l := NewAccessLog(test.format)
+		l.Output = buf
+		serveAccessLog(l, nil, MethodGet, test.target)
+		if !regexp.MustCompile(test.want).MatchString(buf.String()) {
+			t.Errorf("expected log matches %q, got %q", test.want, buf.String())
+		}
+	}
+}
+
+func TestAccessLogJSON(t *testing.T) {
+	buf := &bytes.Buffer{}
+	l := NewAccessLog(JSONLogFormat)
+	l.Output = buf
+	serveAccessLog(l, nil, MethodGet, "/users/foo")
+
+	var entry AccessLogEntry
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatal(err)
+	}
+	if entry.Method != MethodGet || entry.Path != "/users/foo" || entry.Route != "/users/:name" ||
+		entry.Status != 201 || entry.Size != 5 || entry.User != "frank" || entry.UserAgent != "gem-test" {
+		t.Errorf("unexpected entry %+v", entry)
+	}
+}
+
+func TestAccessLogFilter(t *testing.T) {
+	buf := &bytes.Buffer{}
+	l := NewAccessLog("{{.Path}}")
+	l.Output = buf
+	l.SkipPaths = []string{"/empty", "/users/b*"}
+	l.Filter = func(entry *AccessLogEntry) bool {
+		return entry.Status >= 300
+	}
+
+	for _, target := range []string{"/empty", "/users/bar", "/users/foo", "/nope"} {
+		serveAccessLog(l, nil, MethodGet, target)
+	}
+	if buf.String() != "/nope\n" {
+		t.Errorf("expected log %q, got %q", "/nope\n", buf.String())
+	}
+}
+
+func TestAccessLogLogger(t *testing.T) {
+	buf := &bytes.Buffer{}
+	srv := New("")
+	srv.SetLogger(log.New(buf, 0, log.LevelAll))
+	serveAccessLog(NewAccessLog("{{.Status}}"), srv, MethodGet, "/users/foo")
+	if !strings.HasSuffix(buf.String(), "201\n") {
+		t.Errorf("expected log %q, got %q", "201\n", buf.String())
+	}
+}
+
+func TestAccessLogInvalidFormat(t *testing.T) {
+	if recv := catchPanic(func() {
+		NewAccessLog("{{.Status").Wrap(HandlerFunc(func(ctx *Context) {}))
+	}); recv == nil {
+		t.Error("invalid format did not panic")
+	}
+
+	buf := &bytes.Buffer{}
+	l := NewAccessLog("{{.Foo}}")
+	l.Output = buf
+	serveAccessLog(l, nil, MethodGet, "/empty")
+	if !strings.HasPrefix(buf.String(), "access log error") {
+		t.Errorf("expected execution error, got %q", buf.String())
+	}
+}
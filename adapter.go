@@ -0,0 +1,220 @@
+// Copyright 2016 The Gem Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file.
+
+package gem
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// contextKey is the key of Context in the context of http.Request.
+type contextKey struct{}
+
+// ContextFromRequest returns the Context that is associated with the
+// request, it is useful to access the route's parameters and user
+// values in the standard net/http handlers and middlewares, which
+// are adapted by WrapHTTPHandler, WrapHTTPMiddleware and Router.Mount:
+//
+//	router.Mount("/metrics", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+//		if ctx, ok := gem.ContextFromRequest(r); ok {
+//			ctx.Logger().Info("metrics")
+//		}
+//	}))
+func ContextFromRequest(r *http.Request) (*Context, bool) {
+	ctx, ok := r.Context().Value(contextKey{}).(*Context)
+	return ctx, ok
+}
+
+// httpRequest returns the request that is associated with ctx, the
+// ctx.Request is replaced if it is not associated yet.
+func (ctx *Context) httpRequest() *http.Request {
+	if c, ok := ContextFromRequest(ctx.Request); ok && c == ctx {
+		return ctx.Request
+	}
+
+	ctx.Request = ctx.Request.WithContext(context.WithValue(ctx.Request.Context(), contextKey{}, ctx))
+	return ctx.Request
+}
+
+// WrapHTTPHandler adapts the http.Handler to HandlerFunc, the Context
+// can be retrieved from the request via ContextFromRequest.
+func WrapHTTPHandler(h http.Handler) HandlerFunc {
+	return HandlerFunc(func(ctx *Context) {
+		h.ServeHTTP(ctx.Response, ctx.httpRequest())
+	})
+}
+
+// WrapHTTPMiddleware adapts the standard net/http middleware to
+// Middleware, such as:
+//
+//	router.Use(gem.WrapHTTPMiddleware(handlers.CompressHandler))
+//
+// The request and response writer that passed to the next handler by
+// the middleware are set to Context.Request and Context.Response, and
+// restored after the middleware returned.
+func WrapHTTPMiddleware(m func(http.Handler) http.Handler) Middleware {
+	return httpMiddleware(m)
+}
+
+type httpMiddleware func(http.Handler) http.Handler
+
+// Wrap implements the Middleware interface.
+func (m httpMiddleware) Wrap(next Handler) Handler {
+	h := m(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, ok := ContextFromRequest(r)
+		if !ok {
+			// the middleware created a new request without
+			// inheriting the context.
+			ctx = newContext(nil, w, r)
+		}
+
+		ctx.Request, ctx.Response = r, w
+		next.Handle(ctx)
+	}))
+
+	return HandlerFunc(func(ctx *Context) {
+		req, w := ctx.httpRequest(), ctx.Response
+		h.ServeHTTP(w, req)
+		ctx.Request, ctx.Response = req, w
+	})
+}
+
+// mountMethods are the request methods that are routed to
+// the handler mounted by Router.Mount.
+var mountMethods = []string{
+	MethodGet, MethodHead, MethodPost, MethodPut, MethodPatch, MethodDelete, MethodOptions,
+}
+
+// Mount mounts the http.Handler on the given prefix, the requests of
+// the prefix and its sub-paths are routed to the handler, with the
+// prefix stripped from the request path, such as:
+//
+//	router.Mount("/admin", adminRouter)
+//	router.Mount("/static", http.FileServer(http.Dir("/var/www")))
+//
+// The prefix must not be empty or "/", since the catch-all route of the
+// root path conflicts with the other routes. The name of option refers
+// to the prefix, see Router.URL.
+//
+// The handlers that require the full path, such as the net/http/pprof,
+// should be registered via WrapHTTPHandler instead:
+//
+//	router.GET("/debug/pprof/*name", gem.WrapHTTPHandler(http.HandlerFunc(pprof.Index)))
+func (r *Router) Mount(prefix string, handler http.Handler, opts ...*HandlerOption) {
+	prefix = strings.TrimSuffix(prefix, "/")
+	if prefix == "" {
+		panic("mount prefix must not be empty or '/'")
+	}
+
+	handle := WrapHTTPHandler(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		ctx, _ := ContextFromRequest(req)
+
+		// the catch-all parameter is always the last one, it is stripped
+		// so that it is not inherited by the mounted router.
+		path := "/"
+		params := ctx.params
+		if n := len(params); n > ctx.inheritedParams && params[n-1].Key == "mountpath" {
+			path = params[n-1].Value
+			ctx.params = params[:n-1]
+			defer func() {
+				ctx.params = params
+			}()
+		}
+
+		r2 := new(http.Request)
+		*r2 = *req
+		r2.URL = new(url.URL)
+		*r2.URL = *req.URL
+		r2.URL.Path = path
+		r2.URL.RawPath = ""
+		handler.ServeHTTP(w, r2)
+	}))
+
+	// the name is registered once for the GET route of the prefix.
+	named, unnamed := opts, opts
+	if len(opts) > 0 && opts[0] != nil && opts[0].Name != "" {
+		option := *opts[0]
+		option.Name = ""
+		unnamed = []*HandlerOption{&option}
+	}
+
+	for _, method := range mountMethods {
+		if method == MethodGet {
+			r.Handle(method, prefix, handle, named...)
+		} else {
+			r.Handle(method, prefix, handle, unnamed...)
+		}
+		r.Handle(method, prefix+"/*mountpath", handle, unnamed...)
+	}
+}
+
+// Mount mounts the http.Handler on the given prefix, the prefix would
+// be prefixed with the group's prefix.
+//
+// See Router.Mount.
+func (g *Group) Mount(prefix string, handler http.Handler, opts ...*HandlerOption) {
+	g.router.Mount(g.path(prefix), handler, g.handlerOption(opts))
+}
+
+// ServeHTTP implements the http.Handler interface, so that the router
+// can be served by the standard net/http server, or mounted in other
+// routers, such as http.ServeMux:
+//
+//	mux := http.NewServeMux()
+//	mux.Handle("/api/", http.StripPrefix("/api", router))
+//
+// The handler is built via Router.Handler on the first request, the
+// middlewares that are registered afterwards are not applied.
+//
+// If the router is mounted in another gem router, the server, request
+// ID, user values and route's parameters are inherited from the parent
+// Context, and the Context is reused if the parent's server does.
+func (r *Router) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	r.httpHandlerOnce.Do(func() {
+		r.httpHandler = r.Handler()
+	})
+
+	// the Context is reused only if the parent's server does.
+	parent, ok := ContextFromRequest(req)
+	reuse := ok && parent.server != nil && parent.server.reuseContext
+
+	var ctx *Context
+	if reuse {
+		ctx, _ = r.contextPool.Get().(*Context)
+	}
+	if ctx == nil {
+		ctx = new(Context)
+	}
+	ctx.reset(w, req)
+	ctx.server = nil
+
+	// the storage of user values is restored before putting ctx back to
+	// the pool, since the parent's one is shared.
+	values := ctx.userValue
+	defer func() {
+		ctx.finish()
+		if !reuse {
+			return
+		}
+		ctx.userValue = values
+		ctx.reset(nil, nil)
+		r.contextPool.Put(ctx)
+	}()
+
+	if ok {
+		ctx.server = parent.server
+		ctx.requestID = parent.requestID
+		if parent.userValue == nil {
+			parent.userValue = new(userValue)
+		}
+		ctx.userValue = parent.userValue
+		ctx.params = append(ctx.params, parent.params...)
+		ctx.inheritedParams = len(ctx.params)
+	}
+
+	r.httpHandler.Handle(ctx)
+}
@@ -0,0 +1,207 @@
+// Copyright 2016 The Gem Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file.
+
+package gem
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestWrapHTTPHandler(t *testing.T) {
+	router := NewRouter()
+	router.GET("/users/:name", WrapHTTPHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, ok := ContextFromRequest(r)
+		if !ok {
+			t.Fatal("expected the context associated with the request")
+		}
+		fmt.Fprintf(w, "%s %v", ctx.Param("name"), ctx.UserValue("foo"))
+	})), &HandlerOption{Middlewares: []Middleware{&userValueMiddleware{"foo", "bar"}}})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(MethodGet, "/users/gopher", nil))
+	if w.Body.String() != "gopher bar" {
+		t.Errorf("expected body %q, got %q", "gopher bar", w.Body.String())
+	}
+
+	if _, ok := ContextFromRequest(httptest.NewRequest(MethodGet, "/", nil)); ok {
+		t.Error("expected no context associated with the request")
+	}
+}
+
+type userValueMiddleware struct {
+	key   string
+	value interface{}
+}
+
+func (m *userValueMiddleware) Wrap(next Handler) Handler {
+	return HandlerFunc(func(ctx *Context) {
+		ctx.SetUserValue(m.key, m.value)
+		next.Handle(ctx)
+	})
+}
+
+type headerResponseWriter struct {
+	http.ResponseWriter
+}
+
+func (w *headerResponseWriter) Write(p []byte) (int, error) {
+	return w.ResponseWriter.Write(append([]byte("wrapped "), p...))
+}
+
+func TestWrapHTTPMiddleware(t *testing.T) {
+	var outer *Context
+	router := NewRouter()
+	router.Use(&recordMiddleware{&outer})
+	router.Use(WrapHTTPMiddleware(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			r.Header.Set("X-Middleware", "true")
+			next.ServeHTTP(&headerResponseWriter{w}, r)
+		})
+	}))
+	router.GET("/users/:name", func(ctx *Context) {
+		if ctx != outer {
+			t.Error("expected the same context")
+		}
+		ctx.Write([]byte(ctx.Param("name") + " " + ctx.Request.Header.Get("X-Middleware")))
+	})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(MethodGet, "/users/gopher", nil))
+	if w.Body.String() != "wrapped gopher true" {
+		t.Errorf("expected body %q, got %q", "wrapped gopher true", w.Body.String())
+	}
+	if _, ok := outer.Response.(*headerResponseWriter); ok {
+		t.Error("expected the response writer to be restored")
+	}
+
+	// the middleware that creates a new request without the context.
+	router = NewRouter()
+	router.Use(WrapHTTPMiddleware(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, httptest.NewRequest(r.Method, r.URL.Path, nil))
+		})
+	}))
+	router.GET("/", func(ctx *Context) {
+		ctx.Write([]byte("ok"))
+	})
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(MethodGet, "/", nil))
+	if w.Body.String() != "ok" {
+		t.Errorf("expected body %q, got %q", "ok", w.Body.String())
+	}
+}
+
+// recordMiddleware records the context.
+type recordMiddleware struct {
+	ctx **Context
+}
+
+func (m *recordMiddleware) Wrap(next Handler) Handler {
+	return HandlerFunc(func(ctx *Context) {
+		*m.ctx = ctx
+		next.Handle(ctx)
+	})
+}
+
+func TestRouterMount(t *testing.T) {
+	fileServer := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, r.Method+" "+r.URL.Path)
+	})
+
+	admin := NewRouter()
+	admin.GET("/", func(ctx *Context) {
+		ctx.Write([]byte("admin index"))
+	})
+	admin.GET("/posts/:id", func(ctx *Context) {
+		fmt.Fprintf(ctx, "tenant %s post %s user %v request %s", ctx.Param("tenant"), ctx.Param("id"), ctx.UserValue("user"), ctx.RequestID())
+	})
+
+	router := NewRouter()
+	router.Use(&userValueMiddleware{"user", "foo"})
+	router.Use(&RequestID{Generator: func() string { return "id" }})
+	router.Mount("/static/", fileServer)
+	router.Mount("/tenants/:tenant/admin", admin)
+	group := router.Group("/v1")
+	group.Mount("/files", fileServer)
+
+	tests := []struct {
+		method, path, want string
+	}{
+		{MethodGet, "/static", "GET /"},
+		{MethodGet, "/static/", "GET /"},
+		{MethodPost, "/static/css/app.css", "POST /css/app.css"},
+		{MethodGet, "/v1/files/a%2Fb", "GET /a/b"},
+		{MethodGet, "/tenants/gem/admin", "admin index"},
+		{MethodGet, "/tenants/gem/admin/posts/1", "tenant gem post 1 user foo request id"},
+	}
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(test.method, test.path, nil))
+		if w.Body.String() != test.want {
+			t.Errorf("%s %s: expected body %q, got %q", test.method, test.path, test.want, w.Body.String())
+		}
+	}
+
+	for _, prefix := range []string{"", "/"} {
+		if recv := catchPanic(func() { NewRouter().Mount(prefix, fileServer) }); recv == nil {
+			t.Errorf("expected panic of prefix %q", prefix)
+		}
+	}
+}
+
+func TestRouterMountNamed(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	router := NewRouter()
+	router.Mount("/admin", handler, NewNamedHandlerOption("admin"))
+	router.Group("/v1").Mount("/files", handler, NewNamedHandlerOption("files"))
+
+	for name, want := range map[string]string{"admin": "/admin", "files": "/v1/files"} {
+		if url, err := router.URL(name); err != nil || url != want {
+			t.Errorf("expected url %q of %q, got %q, %v", want, name, url, err)
+		}
+	}
+}
+
+func TestRouterMountParams(t *testing.T) {
+	admin := NewRouter()
+	admin.GET("/*path", func(ctx *Context) {
+		fmt.Fprintf(ctx, "%v", ctx.Params())
+	})
+
+	router := NewRouter()
+	router.Mount("/tenants/:tenant", admin)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(MethodGet, "/tenants/gem/posts", nil))
+	if want := "[{tenant gem} {path /posts}]"; w.Body.String() != want {
+		t.Errorf("expected params %q, got %q", want, w.Body.String())
+	}
+}
+
+func TestRouterServeHTTP(t *testing.T) {
+	router := NewRouter()
+	router.GET("/hello", func(ctx *Context) {
+		ctx.Logger().Info("no server")
+		ctx.HTML(http.StatusOK, "hello")
+	})
+
+	mux := http.NewServeMux()
+	mux.Handle("/api/", http.StripPrefix("/api", router))
+
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(MethodGet, "/api/hello", nil))
+	if w.Code != http.StatusOK || w.Body.String() != "hello" {
+		t.Errorf("unexpected response %d %q", w.Code, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(MethodGet, "/api/nope", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected status code %d, got %d", http.StatusNotFound, w.Code)
+	}
+}
@@ -6,21 +6,42 @@ package gem
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io/fs"
 	"io/ioutil"
 	"net/http"
 	"path"
 	"path/filepath"
 	"strings"
+	"sync"
+	"time"
 )
 
+var errIncompleteTLSOption = errors.New("both of cert_file and key_file must be specified to serve HTTPS")
+
 // ApplicationCallback is type of func that defines
 type ApplicationCallback func() error
 
-// NewApplication
-func NewApplication(filename string) (*Application, error) {
+// NewApplication returns an application configured by the given
+// JSON file.
+//
+// If the optional fsys is given, such as an embed.FS, the configuration
+// file, templates and assets are all loaded from fsys, the filename and
+// the roots are slash-separated paths of fsys.
+func NewApplication(filename string, fsys ...fs.FS) (*Application, error) {
 	var data []byte
-	data, err := ioutil.ReadFile(filename)
+	var err error
+	var dir string
+	var files fs.FS
+	if len(fsys) > 0 && fsys[0] != nil {
+		files = fsys[0]
+		data, err = fs.ReadFile(files, filename)
+		dir = path.Dir(filename)
+	} else {
+		data, err = ioutil.ReadFile(filename)
+		dir = filepath.Dir(filename)
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -30,13 +51,16 @@ func NewApplication(filename string) (*Application, error) {
 			Addr: ":8080",
 		},
 		AssetsOpt: AssetsOption{
-			Root:          path.Join(filepath.Dir(filename), "assets"),
+			Root:          path.Join(dir, "assets"),
+			FS:            files,
 			HandlerOption: emptyHandlerOption,
 		},
 		TemplatesOpt: TemplatesOption{
-			Root:      path.Join(filepath.Dir(filename), "templates"),
-			Suffix:    ".html",
-			LayoutDir: "layouts",
+			Root:       path.Join(dir, "templates"),
+			FS:         files,
+			Suffix:     ".html",
+			LayoutDir:  "layouts",
+			PartialDir: "partials",
 		},
 		router:      NewRouter(),
 		components:  make(map[string]interface{}),
@@ -90,12 +114,23 @@ func (app *Application) Init() (err error) {
 
 func (app *Application) initAssets() error {
 	for route, dir := range app.AssetsOpt.Dirs {
+		var root http.FileSystem
+		if app.AssetsOpt.FS != nil {
+			sub, err := fs.Sub(app.AssetsOpt.FS, path.Join(app.AssetsOpt.Root, dir))
+			if err != nil {
+				return err
+			}
+			root = http.FS(sub)
+		} else {
+			root = http.Dir(path.Join(app.AssetsOpt.Root, dir))
+		}
+
 		if app.AssetsOpt.HandlerOption != nil {
-			app.router.ServeFiles(route+"/*filepath", http.Dir(path.Join(app.AssetsOpt.Root, dir)), app.AssetsOpt.HandlerOption)
+			app.router.ServeFiles(route+"/*filepath", root, app.AssetsOpt.HandlerOption)
 			continue
 		}
 
-		app.router.ServeFiles(route+"/*filepath", http.Dir(path.Join(app.AssetsOpt.Root, dir)))
+		app.router.ServeFiles(route+"/*filepath", root)
 	}
 
 	return nil
@@ -103,12 +138,22 @@ func (app *Application) initAssets() error {
 
 func (app *Application) initTemplates() (err error) {
 	app.templates = NewTemplates(app.TemplatesOpt.Root)
+	app.templates.FS = app.TemplatesOpt.FS
 	if app.TemplatesOpt.Suffix != "" {
 		app.templates.Suffix = app.TemplatesOpt.Suffix
 	}
 	if app.TemplatesOpt.LayoutDir != "" {
 		app.templates.LayoutDir = app.TemplatesOpt.LayoutDir
 	}
+	if app.TemplatesOpt.PartialDir != "" {
+		app.templates.PartialDir = app.TemplatesOpt.PartialDir
+	}
+	app.templates.Extends = app.TemplatesOpt.Extends
+	app.templates.Development = app.TemplatesOpt.Development
+	app.templates.AssetPrefix = app.TemplatesOpt.AssetPrefix
+	if app.router != nil {
+		app.router.SetTemplates(app.templates)
+	}
 
 	for _, layout := range app.TemplatesOpt.Layouts {
 		filenames := strings.Split(layout, ",")
@@ -120,6 +165,10 @@ func (app *Application) initTemplates() (err error) {
 		}
 	}
 
+	if !app.templates.Development {
+		return app.templates.Compile()
+	}
+
 	return nil
 }
 
@@ -139,6 +188,79 @@ func (app *Application) Close() (errs []error) {
 	return
 }
 
+// Run initializes the application and controllers, and then runs a
+// server configured by ServerOpt, the server serves HTTPS if both of
+// CertFile and KeyFile are specified.
+//
+// The server would be shut down gracefully once received SIGINT or
+// SIGTERM. The close callbacks are invoked and the log file is closed
+// once Run returns, even if the server failed to start.
+func (app *Application) Run() error {
+	opt := app.ServerOpt
+	if (opt.CertFile == "") != (opt.KeyFile == "") {
+		return errIncompleteTLSOption
+	}
+
+	srv, closeFunc, err := app.newServer()
+	if err != nil {
+		return err
+	}
+	defer closeFunc()
+
+	if err = app.Init(); err != nil {
+		return err
+	}
+
+	if err = app.InitControllers(); err != nil {
+		return err
+	}
+
+	if opt.CertFile != "" {
+		return srv.RunTLS(opt.CertFile, opt.KeyFile, app.router.Handler())
+	}
+
+	return srv.Run(app.router.Handler())
+}
+
+// newServer returns a server configured by ServerOpt, and a function
+// that invokes the close callbacks and closes the log file, which is
+// also invoked after the server was shut down, and only takes effect
+// once.
+func (app *Application) newServer() (*Server, func(), error) {
+	opt := app.ServerOpt
+
+	logger, closer, err := newLogger(opt.Logger)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	srv := New(opt.Addr)
+	srv.SetLogger(logger)
+	srv.Server.ReadTimeout = time.Duration(opt.ReadTimeout)
+	srv.Server.ReadHeaderTimeout = time.Duration(opt.ReadHeaderTimeout)
+	srv.Server.WriteTimeout = time.Duration(opt.WriteTimeout)
+	srv.Server.IdleTimeout = time.Duration(opt.IdleTimeout)
+	if opt.ShutdownTimeout != 0 {
+		srv.SetShutdownTimeout(time.Duration(opt.ShutdownTimeout))
+	}
+
+	var once sync.Once
+	closeFunc := func() {
+		once.Do(func() {
+			for _, err := range app.Close() {
+				logger.Error(err)
+			}
+
+			if closer != nil {
+				closer.Close()
+			}
+		})
+	}
+	srv.SetShutdownCallback(closeFunc)
+
+	return srv, closeFunc, nil
+}
+
 // Router returns an instance of router.
 func (app *Application) Router() *Router {
 	return app.router
@@ -5,14 +5,20 @@
 package gem
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path"
 	"reflect"
 	"strconv"
 	"testing"
+	"testing/fstest"
 	"time"
 )
 
@@ -280,3 +286,225 @@ func TestApplication_InitControllers(t *testing.T) {
 		t.Errorf("expected error %q, got %q", expectedErr, err)
 	}
 }
+
+func TestApplication_initTemplatesURLFunc(t *testing.T) {
+	opt := TemplatesOption{
+		Root:      path.Join(os.TempDir(), "url-"+strconv.Itoa(time.Now().Nanosecond())),
+		LayoutDir: "layouts",
+		Layouts:   []string{"main"},
+	}
+	if err := os.MkdirAll(path.Join(opt.Root, opt.LayoutDir), os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	layoutName := path.Join(opt.Root, opt.LayoutDir, "main.html")
+	if err := ioutil.WriteFile(layoutName, []byte(`{{url "user" "foo"}}`), os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+
+	app := &Application{
+		TemplatesOpt: opt,
+		router:       NewRouter(),
+	}
+	app.router.GET("/users/:name", func(ctx *Context) {}, NewNamedHandlerOption("user"))
+	if err := app.initTemplates(); err != nil {
+		t.Fatal(err)
+	}
+
+	layout, err := app.templates.Layout("main")
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf := &bytes.Buffer{}
+	if err = layout.Execute(buf, nil); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != "/users/foo" {
+		t.Errorf("expected url %q, got %q", "/users/foo", buf.String())
+	}
+
+	if app.router.templates != app.templates {
+		t.Error("failed to set the templates of router")
+	}
+}
+
+func TestApplication_Run(t *testing.T) {
+	expectedErr := errors.New("init error")
+	app := &Application{router: NewRouter()}
+	app.SetInitCallback(func() error {
+		return expectedErr
+	})
+	if err := app.Run(); err != expectedErr {
+		t.Errorf("expected error %q, got %v", expectedErr, err)
+	}
+
+	app = &Application{
+		ServerOpt: ServerOption{Addr: "invalid address"},
+		router:    NewRouter(),
+	}
+	if err := app.Run(); err == nil {
+		t.Error("expected listen error, got nil")
+	}
+
+	var closed int
+	app.SetCloseCallback(func() error {
+		closed++
+		return nil
+	})
+	if err := app.Run(); err == nil {
+		t.Error("expected listen error, got nil")
+	}
+	if closed != 1 {
+		t.Errorf("expected close callbacks to be invoked once after failing to listen, got %d", closed)
+	}
+
+	app.ServerOpt.Logger.Levels = []string{"foo"}
+	if err := app.Run(); err == nil || err.Error() != `unknown log level "foo"` {
+		t.Errorf("expected unknown log level error, got %v", err)
+	}
+
+	app.ServerOpt.Logger.Levels = nil
+	app.ServerOpt.CertFile = "cert.pem"
+	if err := app.Run(); err != errIncompleteTLSOption {
+		t.Errorf("expected error %q, got %v", errIncompleteTLSOption, err)
+	}
+}
+
+func TestApplication_newServer(t *testing.T) {
+	output := path.Join(os.TempDir(), "server-"+strconv.Itoa(time.Now().Nanosecond())+".log")
+	defer os.Remove(output)
+
+	data := `{
+		"addr": ":8000",
+		"read_timeout": "1s",
+		"read_header_timeout": "2s",
+		"write_timeout": "3s",
+		"idle_timeout": "1m",
+		"shutdown_timeout": "5s",
+		"logger": {"output": "` + output + `", "levels": ["info", "error"]}
+	}`
+	app := &Application{router: NewRouter()}
+	if err := json.Unmarshal([]byte(data), &app.ServerOpt); err != nil {
+		t.Fatal(err)
+	}
+
+	var closed bool
+	app.SetCloseCallback(func() error {
+		closed = true
+		return errors.New("close error")
+	})
+
+	srv, closeFunc, err := app.newServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if srv.Server.Addr != ":8000" {
+		t.Errorf("expected addr %q, got %q", ":8000", srv.Server.Addr)
+	}
+	timeouts := []struct {
+		got, want time.Duration
+	}{
+		{srv.Server.ReadTimeout, time.Second},
+		{srv.Server.ReadHeaderTimeout, 2 * time.Second},
+		{srv.Server.WriteTimeout, 3 * time.Second},
+		{srv.Server.IdleTimeout, time.Minute},
+		{srv.shutdownTimeout, 5 * time.Second},
+	}
+	for _, timeout := range timeouts {
+		if timeout.got != timeout.want {
+			t.Errorf("expected timeout %s, got %s", timeout.want, timeout.got)
+		}
+	}
+
+	if err = srv.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if !closed {
+		t.Error("close callbacks were not invoked after shutting down")
+	}
+	logs, err := ioutil.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(logs, []byte("close error")) {
+		t.Errorf("expected the close error to be logged, got %q", logs)
+	}
+	// the close callbacks are invoked only once.
+	closed = false
+	closeFunc()
+	if closed {
+		t.Error("close callbacks were invoked twice")
+	}
+}
+
+func TestDuration(t *testing.T) {
+	var d Duration
+	if err := json.Unmarshal([]byte(`"1m30s"`), &d); err != nil {
+		t.Fatal(err)
+	}
+	if time.Duration(d) != 90*time.Second {
+		t.Errorf("expected duration %s, got %s", 90*time.Second, time.Duration(d))
+	}
+
+	data, err := json.Marshal(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != `"1m30s"` {
+		t.Errorf("expected json %s, got %s", `"1m30s"`, data)
+	}
+
+	if err = json.Unmarshal([]byte(`"foo"`), &d); err == nil {
+		t.Error("expected invalid duration error, got nil")
+	}
+	if err = json.Unmarshal([]byte(`10`), &d); err == nil {
+		t.Error("expected invalid duration error, got nil")
+	}
+}
+
+func TestNewApplicationFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"app/app.json":                    {Data: []byte(`{"assets": {"dirs": {"/static": "css"}}, "templates": {"layouts": ["main"]}}`)},
+		"app/assets/css/main.css":         {Data: []byte("body{}")},
+		"app/templates/layouts/main.html": {Data: []byte(`<main>{{block "body" .}}{{end}}</main>`)},
+		"app/templates/users/index.html":  {Data: []byte(`{{define "body"}}users{{end}}`)},
+	}
+
+	if _, err := NewApplication("app/missing.json", fsys); err == nil {
+		t.Error("expected non-nil error, got nil")
+	}
+
+	app, err := NewApplication("app/app.json", fsys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if app.AssetsOpt.Root != "app/assets" || app.TemplatesOpt.Root != "app/templates" {
+		t.Errorf("unexpected roots %q and %q", app.AssetsOpt.Root, app.TemplatesOpt.Root)
+	}
+	if err = app.Init(); err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest(MethodGet, "/static/main.css", nil)
+	app.router.handle(newContext(nil, w, r))
+	if w.Body.String() != "body{}" {
+		t.Errorf("expected body %q, got %q", "body{}", w.Body.String())
+	}
+
+	tmpl, err := app.templates.Render("main", "users/index")
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf := &bytes.Buffer{}
+	if err = tmpl.Execute(buf, nil); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != "<main>users</main>" {
+		t.Errorf("expected html %q, got %q", "<main>users</main>", buf.String())
+	}
+
+	app.AssetsOpt.Dirs = map[string]string{"/invalid": "../../.."}
+	if err = app.initAssets(); err == nil {
+		t.Error("expected invalid path error, got nil")
+	}
+}
@@ -0,0 +1,326 @@
+// Copyright 2016 The Gem Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file.
+
+package gem
+
+import (
+	"encoding"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/textproto"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// defaultMaxMemory is the maximum bytes of the multipart form's
+// non-file parts that would be stored in memory.
+const defaultMaxMemory = 32 << 20
+
+var (
+	errUnsupportedMediaType = errors.New("unsupported media type")
+	errEmptyRequestBody     = errors.New("empty request body")
+	errTruncatedRequestBody = errors.New("unexpected end of request body")
+	errInvalidBindTarget    = errors.New("the binding target must be a non-nil pointer to struct")
+)
+
+// BindError records a failed conversion of request data
+// into a struct field.
+type BindError struct {
+	Field string // the name of struct field
+	Value string // the raw value
+	Err   error  // the reason the conversion failed
+}
+
+func (e *BindError) Error() string {
+	return fmt.Sprintf("invalid value %q of field %q: %s", e.Value, e.Field, e.Err)
+}
+
+// Bind decodes the request body into v by the Content-Type header.
+//
+// The following media types are supported:
+//
+//	application/json, */*+json          - decoded by encoding/json
+//	application/xml, text/xml, */*+xml  - decoded by encoding/xml
+//	application/x-www-form-urlencoded   - mapped by "form" tag
+//	multipart/form-data                 - mapped by "form" tag
+//
+// For the form media types, v must be a pointer to struct, the fields of
+// type *multipart.FileHeader and []*multipart.FileHeader are filled in with
+// the uploaded files.
+//
+// The decoded data is validated by Validate, a ValidationErrors would be
+// returned if any rule is not satisfied.
+func (ctx *Context) Bind(v interface{}) error {
+	if err := ctx.bind(v); err != nil {
+		return err
+	}
+
+	return Validate(v)
+}
+
+func (ctx *Context) bind(v interface{}) error {
+	mediaType, _, err := mime.ParseMediaType(ctx.Request.Header.Get("Content-Type"))
+	if err != nil {
+		return errUnsupportedMediaType
+	}
+
+	switch {
+	case mediaType == MIMEJSON || strings.HasSuffix(mediaType, "+json"):
+		if ctx.Request.Body == nil {
+			return errEmptyRequestBody
+		}
+		return decodeError(json.NewDecoder(ctx.Request.Body).Decode(v))
+	case mediaType == MIMEXML || mediaType == "text/xml" || strings.HasSuffix(mediaType, "+xml"):
+		if ctx.Request.Body == nil {
+			return errEmptyRequestBody
+		}
+		return decodeError(xml.NewDecoder(ctx.Request.Body).Decode(v))
+	case mediaType == MIMEForm:
+		if err = ctx.Request.ParseForm(); err != nil {
+			return err
+		}
+		return bindData(v, "form", valuesGetter(ctx.Request.PostForm), nil)
+	case mediaType == MIMEMultipartForm:
+		if err = ctx.Request.ParseMultipartForm(defaultMaxMemory); err != nil {
+			return err
+		}
+		form := ctx.Request.MultipartForm
+		return bindData(v, "form", valuesGetter(form.Value), form.File)
+	}
+
+	return errUnsupportedMediaType
+}
+
+// decodeError converts the EOF errors of decoding the request body, the
+// empty body results in io.EOF, and the truncated one io.ErrUnexpectedEOF.
+func decodeError(err error) error {
+	switch err {
+	case io.EOF:
+		return errEmptyRequestBody
+	case io.ErrUnexpectedEOF:
+		return errTruncatedRequestBody
+	}
+
+	return err
+}
+
+// BindQuery maps the URL query values into the struct pointed to by v,
+// the fields are matched by "query" tag.
+func (ctx *Context) BindQuery(v interface{}) error {
+	return bindData(v, "query", valuesGetter(ctx.Request.URL.Query()), nil)
+}
+
+// BindParams maps the route's parameters into the struct pointed to by v,
+// the fields are matched by "param" tag.
+func (ctx *Context) BindParams(v interface{}) error {
+	return bindData(v, "param", func(name string) []string {
+		if value, ok := ctx.params.ByName(name); ok {
+			return []string{value}
+		}
+		return nil
+	}, nil)
+}
+
+// BindHeader maps the request headers into the struct pointed to by v,
+// the fields are matched by "header" tag, case-insensitive.
+func (ctx *Context) BindHeader(v interface{}) error {
+	return bindData(v, "header", func(name string) []string {
+		return ctx.Request.Header[textproto.CanonicalMIMEHeaderKey(name)]
+	}, nil)
+}
+
+func valuesGetter(values map[string][]string) func(string) []string {
+	return func(name string) []string {
+		return values[name]
+	}
+}
+
+var (
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+	fileHeaderType      = reflect.TypeOf((*multipart.FileHeader)(nil))
+	fileHeadersType     = reflect.TypeOf([]*multipart.FileHeader(nil))
+)
+
+// bindData maps the values into the fields of struct pointed to by ptr,
+// the name of field is specified by the given tag, the field name would
+// be used if the tag is absent, and "-" means the field is ignored.
+//
+// The nested struct and pointer to struct fields without tag are mapped
+// recursively, the nil pointer is allocated only if any of its fields
+// was set.
+func bindData(ptr interface{}, tag string, get func(string) []string, files map[string][]*multipart.FileHeader) error {
+	v := reflect.ValueOf(ptr)
+	if v.Kind() != reflect.Ptr || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return errInvalidBindTarget
+	}
+
+	_, err := bindStruct(v.Elem(), tag, get, files)
+	return err
+}
+
+// bindStruct reports whether any field of v was set.
+func bindStruct(v reflect.Value, tag string, get func(string) []string, files map[string][]*multipart.FileHeader) (bool, error) {
+	bound := false
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		// skip unexported fields.
+		if field.PkgPath != "" && !field.Anonymous {
+			continue
+		}
+
+		name := field.Tag.Get(tag)
+		if idx := strings.IndexByte(name, ','); idx >= 0 {
+			name = name[:idx]
+		}
+		if name == "-" {
+			continue
+		}
+
+		fv := v.Field(i)
+
+		// nested struct without tag.
+		if name == "" && fv.Kind() == reflect.Struct && !reflect.PtrTo(field.Type).Implements(textUnmarshalerType) {
+			ok, err := bindStruct(fv, tag, get, files)
+			if err != nil {
+				return false, err
+			}
+			bound = bound || ok
+			continue
+		}
+		// nested pointer to struct without tag, it is allocated
+		// only if any of its fields was set.
+		if name == "" && fv.Kind() == reflect.Ptr && field.Type.Elem().Kind() == reflect.Struct &&
+			!field.Type.Implements(textUnmarshalerType) && fv.CanSet() {
+			elem := fv
+			if fv.IsNil() {
+				elem = reflect.New(field.Type.Elem())
+			}
+			ok, err := bindStruct(elem.Elem(), tag, get, files)
+			if err != nil {
+				return false, err
+			}
+			if ok {
+				fv.Set(elem)
+				bound = true
+			}
+			continue
+		}
+		if field.PkgPath != "" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		switch field.Type {
+		case fileHeaderType:
+			if fhs := files[name]; len(fhs) > 0 {
+				fv.Set(reflect.ValueOf(fhs[0]))
+				bound = true
+			}
+			continue
+		case fileHeadersType:
+			if fhs := files[name]; len(fhs) > 0 {
+				fv.Set(reflect.ValueOf(fhs))
+				bound = true
+			}
+			continue
+		}
+
+		values := get(name)
+		if len(values) == 0 {
+			continue
+		}
+
+		if err := setField(fv, values); err != nil {
+			return false, &BindError{Field: field.Name, Value: values[0], Err: err}
+		}
+		bound = true
+	}
+
+	return bound, nil
+}
+
+func setField(v reflect.Value, values []string) error {
+	if v.Kind() == reflect.Slice && !v.Addr().Type().Implements(textUnmarshalerType) {
+		slice := reflect.MakeSlice(v.Type(), len(values), len(values))
+		for i, value := range values {
+			if err := setValue(slice.Index(i), value); err != nil {
+				return err
+			}
+		}
+		v.Set(slice)
+		return nil
+	}
+
+	return setValue(v, values[0])
+}
+
+func setValue(v reflect.Value, value string) error {
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		return setValue(v.Elem(), value)
+	}
+
+	if u, ok := v.Addr().Interface().(encoding.TextUnmarshaler); ok {
+		return u.UnmarshalText([]byte(value))
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(value)
+	case reflect.Bool:
+		if value == "" {
+			value = "false"
+		} else if value == "on" {
+			// the value of checked checkbox.
+			value = "true"
+		}
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return err.(*strconv.NumError).Err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if value == "" {
+			value = "0"
+		}
+		i, err := strconv.ParseInt(value, 10, v.Type().Bits())
+		if err != nil {
+			return err.(*strconv.NumError).Err
+		}
+		v.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if value == "" {
+			value = "0"
+		}
+		u, err := strconv.ParseUint(value, 10, v.Type().Bits())
+		if err != nil {
+			return err.(*strconv.NumError).Err
+		}
+		v.SetUint(u)
+	case reflect.Float32, reflect.Float64:
+		if value == "" {
+			value = "0"
+		}
+		f, err := strconv.ParseFloat(value, v.Type().Bits())
+		if err != nil {
+			return err.(*strconv.NumError).Err
+		}
+		v.SetFloat(f)
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+
+	return nil
+}
@@ -0,0 +1,236 @@
+// Copyright 2016 The Gem Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file.
+
+package gem
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+type testBindingUser struct {
+	Name   string   `json:"name" xml:"name" form:"name" query:"name"`
+	Age    int      `json:"age" xml:"age" form:"age" query:"age"`
+	Tags   []string `json:"tags" xml:"tag" form:"tag" query:"tag"`
+	Admin  bool     `form:"admin" query:"admin"`
+	Score  *float64 `form:"score" query:"score"`
+	Ignore string   `form:"-" query:"-"`
+}
+
+func newBindingRequest(method, url, contentType, body string) *http.Request {
+	req, _ := http.NewRequest(method, url, strings.NewReader(body))
+	req.Header.Set("Content-Type", contentType)
+	return req
+}
+
+func TestContext_Bind(t *testing.T) {
+	score := 9.5
+	want := testBindingUser{Name: "foo", Age: 18, Tags: []string{"a", "b"}}
+	wantForm := testBindingUser{Name: "foo", Age: 18, Tags: []string{"a", "b"}, Admin: true, Score: &score}
+
+	tests := []struct {
+		req  *http.Request
+		want testBindingUser
+	}{
+		{newBindingRequest(MethodPost, "/", MIMEJSON, `{"name":"foo","age":18,"tags":["a","b"]}`), want},
+		{newBindingRequest(MethodPost, "/", "application/vnd.api+json; charset=utf-8", `{"name":"foo","age":18,"tags":["a","b"]}`), want},
+		{newBindingRequest(MethodPost, "/", MIMEXML, `<user><name>foo</name><age>18</age><tag>a</tag><tag>b</tag></user>`), want},
+		{newBindingRequest(MethodPost, "/", "text/xml", `<user><name>foo</name><age>18</age><tag>a</tag><tag>b</tag></user>`), want},
+		{newBindingRequest(MethodPost, "/", MIMEForm, `name=foo&age=18&tag=a&tag=b&admin=on&score=9.5&Ignore=bar`), wantForm},
+	}
+	for _, test := range tests {
+		ctx := &Context{Request: test.req}
+		var user testBindingUser
+		if err := ctx.Bind(&user); err != nil {
+			t.Errorf("failed to bind %s: %s", test.req.Header.Get("Content-Type"), err)
+			continue
+		}
+		if !reflect.DeepEqual(user, test.want) {
+			t.Errorf("expected %+v for %s, got %+v", test.want, test.req.Header.Get("Content-Type"), user)
+		}
+	}
+}
+
+func TestContext_BindMultipartForm(t *testing.T) {
+	body := &bytes.Buffer{}
+	mw := multipart.NewWriter(body)
+	mw.WriteField("name", "foo")
+	fw, _ := mw.CreateFormFile("avatar", "avatar.png")
+	fw.Write([]byte("avatar"))
+	for i := 0; i < 2; i++ {
+		fw, _ = mw.CreateFormFile("photos", "photo"+strconv.Itoa(i)+".png")
+		fw.Write([]byte("photo"))
+	}
+	mw.Close()
+
+	req, _ := http.NewRequest(MethodPost, "/", body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	ctx := &Context{Request: req}
+
+	var form struct {
+		Name   string                  `form:"name"`
+		Avatar *multipart.FileHeader   `form:"avatar"`
+		Photos []*multipart.FileHeader `form:"photos"`
+		Empty  *multipart.FileHeader   `form:"empty"`
+	}
+	if err := ctx.Bind(&form); err != nil {
+		t.Fatal(err)
+	}
+	if form.Name != "foo" {
+		t.Errorf("expected name %q, got %q", "foo", form.Name)
+	}
+	if form.Avatar == nil || form.Avatar.Filename != "avatar.png" {
+		t.Errorf("failed to bind file, got %v", form.Avatar)
+	}
+	if len(form.Photos) != 2 {
+		t.Errorf("expected %d files, got %d", 2, len(form.Photos))
+	}
+	if form.Empty != nil {
+		t.Errorf("expected nil file, got %v", form.Empty)
+	}
+}
+
+func TestContext_BindError(t *testing.T) {
+	var user testBindingUser
+
+	ctx := &Context{Request: newBindingRequest(MethodPost, "/", "text/plain", "")}
+	if err := ctx.Bind(&user); err != errUnsupportedMediaType {
+		t.Errorf("expected error %q, got %v", errUnsupportedMediaType, err)
+	}
+
+	ctx = &Context{Request: newBindingRequest(MethodPost, "/", "", "")}
+	if err := ctx.Bind(&user); err != errUnsupportedMediaType {
+		t.Errorf("expected error %q, got %v", errUnsupportedMediaType, err)
+	}
+
+	req, _ := http.NewRequest(MethodPost, "/", nil)
+	req.Header.Set("Content-Type", MIMEJSON)
+	ctx = &Context{Request: req}
+	if err := ctx.Bind(&user); err != errEmptyRequestBody {
+		t.Errorf("expected error %q, got %v", errEmptyRequestBody, err)
+	}
+
+	for _, contentType := range []string{MIMEJSON, MIMEXML} {
+		ctx = &Context{Request: newBindingRequest(MethodPost, "/", contentType, "")}
+		if err := ctx.Bind(&user); err != errEmptyRequestBody {
+			t.Errorf("%s: expected error %q, got %v", contentType, errEmptyRequestBody, err)
+		}
+	}
+
+	ctx = &Context{Request: newBindingRequest(MethodPost, "/", MIMEJSON, `{"name":`)}
+	if err := ctx.Bind(&user); err != errTruncatedRequestBody {
+		t.Errorf("expected error %q, got %v", errTruncatedRequestBody, err)
+	}
+
+	ctx = &Context{Request: newBindingRequest(MethodPost, "/", MIMEForm, "name=foo")}
+	if err := ctx.Bind(user); err != errInvalidBindTarget {
+		t.Errorf("expected error %q, got %v", errInvalidBindTarget, err)
+	}
+
+	ctx = &Context{Request: newBindingRequest(MethodPost, "/", MIMEForm, "age=foo")}
+	err := ctx.Bind(&user)
+	if bindErr, ok := err.(*BindError); !ok || bindErr.Field != "Age" || bindErr.Value != "foo" {
+		t.Errorf("expected *BindError of field %q, got %v", "Age", err)
+	}
+}
+
+func TestContext_BindQuery(t *testing.T) {
+	req, _ := http.NewRequest(MethodGet, "/?name=foo&age=18&tag=a&tag=b&Ignore=bar", nil)
+	ctx := &Context{Request: req}
+
+	var user testBindingUser
+	if err := ctx.BindQuery(&user); err != nil {
+		t.Fatal(err)
+	}
+	want := testBindingUser{Name: "foo", Age: 18, Tags: []string{"a", "b"}}
+	if !reflect.DeepEqual(user, want) {
+		t.Errorf("expected %+v, got %+v", want, user)
+	}
+}
+
+type testBindingPage struct {
+	Page uint8 `query:"page"`
+}
+
+func TestContext_BindParams(t *testing.T) {
+	ctx := &Context{
+		params: PathParams{{"id", "6ba7b810-9dad-11d1-80b4-00c04fd430c8"}, {"page", "2"}},
+	}
+
+	var params struct {
+		ID UUID `param:"id"`
+		testBindingPage
+		unexported string
+	}
+	if err := ctx.BindParams(&params); err != nil {
+		t.Fatal(err)
+	}
+	if params.ID.String() != "6ba7b810-9dad-11d1-80b4-00c04fd430c8" {
+		t.Errorf("expected id %q, got %q", "6ba7b810-9dad-11d1-80b4-00c04fd430c8", params.ID)
+	}
+
+	// the embedded struct field is matched by its field name if the tag is absent.
+	if params.Page != 0 {
+		t.Errorf("expected page %d, got %d", 0, params.Page)
+	}
+	ctx.params = PathParams{{"Page", "256"}}
+	if err := ctx.BindParams(&params); err == nil {
+		t.Error("expected out of range error, got nil")
+	}
+}
+
+func TestContext_BindHeader(t *testing.T) {
+	req, _ := http.NewRequest(MethodGet, "/", nil)
+	req.Header.Set("X-Request-Id", "foo")
+	req.Header.Add("Accept-Language", "en")
+	req.Header.Add("Accept-Language", "zh")
+	ctx := &Context{Request: req}
+
+	var header struct {
+		RequestID string   `header:"x-request-id"`
+		Languages []string `header:"Accept-Language"`
+		Missing   string   `header:"X-Missing"`
+	}
+	if err := ctx.BindHeader(&header); err != nil {
+		t.Fatal(err)
+	}
+	if header.RequestID != "foo" {
+		t.Errorf("expected request id %q, got %q", "foo", header.RequestID)
+	}
+	if !reflect.DeepEqual(header.Languages, []string{"en", "zh"}) {
+		t.Errorf("expected languages %v, got %v", []string{"en", "zh"}, header.Languages)
+	}
+}
+
+func TestContext_BindNestedPointer(t *testing.T) {
+	req, _ := http.NewRequest(MethodGet, "/?page=2", nil)
+	ctx := &Context{Request: req}
+
+	var query struct {
+		Page    *testBindingPage
+		Missing *struct {
+			Sort string `query:"sort"`
+		}
+	}
+	if err := ctx.BindQuery(&query); err != nil {
+		t.Fatal(err)
+	}
+	if query.Page == nil || query.Page.Page != 2 {
+		t.Errorf("expected page %d, got %+v", 2, query.Page)
+	}
+	// the pointer is not allocated if none of its fields was set.
+	if query.Missing != nil {
+		t.Errorf("expected nil pointer, got %+v", query.Missing)
+	}
+
+	ctx.Request, _ = http.NewRequest(MethodGet, "/?page=256", nil)
+	if err := ctx.BindQuery(&query); err == nil {
+		t.Error("expected out of range error, got nil")
+	}
+}
@@ -0,0 +1,109 @@
+// Copyright 2016 The Gem Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file.
+
+package gem
+
+import (
+	"regexp"
+	"strconv"
+)
+
+// paramConstraints contains the built-in constraints of route's parameter,
+// such as ":id<int>". Any other constraint is treated as a regular
+// expression which must match the whole value, such as ":slug<[a-z0-9-]+>".
+var paramConstraints = map[string]func(string) bool{
+	"int": func(s string) bool {
+		_, err := strconv.ParseInt(s, 10, 64)
+		return err == nil
+	},
+	"uint": func(s string) bool {
+		_, err := strconv.ParseUint(s, 10, 64)
+		return err == nil
+	},
+	"alpha": func(s string) bool {
+		for i := 0; i < len(s); i++ {
+			if !isAlpha(s[i]) {
+				return false
+			}
+		}
+		return len(s) > 0
+	},
+	"alnum": func(s string) bool {
+		for i := 0; i < len(s); i++ {
+			if !isAlpha(s[i]) && !isDigit(s[i]) {
+				return false
+			}
+		}
+		return len(s) > 0
+	},
+	"uuid": func(s string) bool {
+		_, err := ParseUUID(s)
+		return err == nil
+	},
+}
+
+func isAlpha(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z'
+}
+
+func isDigit(c byte) bool {
+	return '0' <= c && c <= '9'
+}
+
+// compileConstraint returns a function that reports whether the value of
+// parameter satisfies the given constraint.
+func compileConstraint(expr string) (func(string) bool, error) {
+	if match, ok := paramConstraints[expr]; ok {
+		return match, nil
+	}
+
+	re, err := regexp.Compile("^(?:" + expr + ")$")
+	if err != nil {
+		return nil, err
+	}
+
+	return re.MatchString, nil
+}
+
+// scanConstraint returns the index after the '>' that closes the
+// constraint beginning at path[i], which must be '<'.
+// Angle brackets can be nested, e.g. "<(?P<year>\d{4})>", and
+// escaped by backslash.
+// -1 would be returned if the constraint is unterminated.
+func scanConstraint(path string, i int) int {
+	depth := 0
+	for ; i < len(path); i++ {
+		switch path[i] {
+		case '\\':
+			i++
+		case '<':
+			depth++
+		case '>':
+			if depth--; depth == 0 {
+				return i + 1
+			}
+		}
+	}
+
+	return -1
+}
+
+// scanWildcard returns the name, constraint and the end of wildcard
+// which begins at path[i], the end is either '/' or path end.
+func scanWildcard(path string, i int) (name, constraint string, end int) {
+	end = i + 1
+	for end < len(path) && path[end] != '/' {
+		if path[end] == '<' && path[i] == ':' {
+			name = path[i+1 : end]
+			closing := scanConstraint(path, end)
+			if closing < 0 {
+				return name, path[end+1:], -1
+			}
+			return name, path[end+1 : closing-1], closing
+		}
+		end++
+	}
+
+	return path[i+1 : end], "", end
+}
@@ -5,19 +5,25 @@
 package gem
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
 	"encoding/xml"
 	"errors"
 	"mime/multipart"
 	"net/http"
 	"net/url"
+	"time"
 )
 
 // MIME types
 const (
-	MIMEHTML = "text/html"
-	MIMEJSON = "application/json"
-	MIMEXML  = "application/xml"
+	MIMEHTML          = "text/html"
+	MIMEText          = "text/plain"
+	MIMEJSON          = "application/json"
+	MIMEXML           = "application/xml"
+	MIMEForm          = "application/x-www-form-urlencoded"
+	MIMEMultipartForm = "multipart/form-data"
 )
 
 // Request methods.
@@ -33,10 +39,49 @@ const (
 )
 
 func newContext(s *Server, w http.ResponseWriter, r *http.Request) *Context {
-	return &Context{
-		server:   s,
-		Request:  r,
-		Response: w,
+	ctx := &Context{server: s}
+	ctx.reset(w, r)
+	return ctx
+}
+
+// reset resets ctx to serve the given request, the storage of the route's
+// parameters and user values are reused.
+func (ctx *Context) reset(w http.ResponseWriter, r *http.Request) {
+	ctx.router = nil
+	ctx.route = ""
+	ctx.requestID = ""
+	ctx.params = ctx.params[:0]
+	ctx.inheritedParams = 0
+	ctx.logger = nil
+	if ctx.userValue != nil {
+		values := *ctx.userValue
+		for i := range values {
+			values[i] = userData{}
+		}
+		*ctx.userValue = values[:0]
+	}
+
+	for i := range ctx.finishFuncs {
+		ctx.finishFuncs[i] = nil
+	}
+	ctx.finishFuncs = ctx.finishFuncs[:0]
+
+	ctx.Request = r
+	ctx.installResponseWriter(w)
+}
+
+// onFinish registers the function that is called once the handler of
+// the request returned, see finish.
+func (ctx *Context) onFinish(f func()) {
+	ctx.finishFuncs = append(ctx.finishFuncs, f)
+}
+
+// finish calls the functions registered by onFinish in reverse order,
+// it is called by the Server and Router.ServeHTTP after the handler of
+// the request returned.
+func (ctx *Context) finish() {
+	for i := len(ctx.finishFuncs) - 1; i >= 0; i-- {
+		ctx.finishFuncs[i]()
 	}
 }
 
@@ -48,9 +93,29 @@ type userData struct {
 }
 
 // Context contains *http.Request and http.Response.
+//
+// The Context is created for each request, unless the Server reuses it,
+// see Server.SetReuseContext.
 type Context struct {
 	server    *Server
+	router    *Router
+	route     string
+	requestID string
+	params    PathParams
 	userValue *userValue
+	logger    FieldLogger
+
+	// inheritedParams is the number of parameters inherited
+	// from the parent router, see Router.ServeHTTP.
+	inheritedParams int
+
+	// rw is the ResponseWriter installed when the request started,
+	// response is the storage of it, see installResponseWriter.
+	rw       *ResponseWriter
+	response ResponseWriter
+
+	// finishFuncs are called after the handler returned, see onFinish.
+	finishFuncs []func()
 
 	Request  *http.Request
 	Response http.ResponseWriter
@@ -77,26 +142,182 @@ func (ctx *Context) SetUserValue(key string, value interface{}) {
 }
 
 // UserValue returns the value stored via SetUserValue* under the given key.
+//
+// For backward compatibility, if no such value was stored, the route's
+// parameter under the given key would be returned, it is recommended to
+// use Param and its variants to get route's parameters, since the user
+// values may shadow the route's parameters.
 func (ctx *Context) UserValue(key string) interface{} {
-	if ctx.userValue == nil {
-		return nil
+	if value, ok := ctx.lookupUserValue(key); ok {
+		return value
 	}
 
-	values := *ctx.userValue
-	for i := 0; i < len(values); i++ {
-		if values[i].key == key {
-			return values[i].value
-		}
+	if value, ok := ctx.params.ByName(key); ok {
+		return value
 	}
 
 	return nil
 }
 
-// Logger returns the server's logger.
-func (ctx *Context) Logger() Logger {
+func (ctx *Context) lookupUserValue(key string) (interface{}, bool) {
+	if ctx.userValue != nil {
+		values := *ctx.userValue
+		for i := 0; i < len(values); i++ {
+			if values[i].key == key {
+				return values[i].value, true
+			}
+		}
+	}
+
+	return nil, false
+}
+
+// Context implements the context.Context interface, so that it can be
+// passed to the downstream libraries directly, the deadline, cancellation
+// signal and values are backed by the request's context.
+var _ context.Context = (*Context)(nil)
+
+func (ctx *Context) requestContext() context.Context {
+	if ctx.Request == nil {
+		return context.Background()
+	}
+
+	return ctx.Request.Context()
+}
+
+// Deadline implements the context.Context interface, it returns the
+// deadline of the request's context.
+func (ctx *Context) Deadline() (deadline time.Time, ok bool) {
+	return ctx.requestContext().Deadline()
+}
+
+// Done implements the context.Context interface, the returned channel is
+// closed when the client's connection closes, the request is canceled,
+// or the deadline expires.
+func (ctx *Context) Done() <-chan struct{} {
+	return ctx.requestContext().Done()
+}
+
+// Err implements the context.Context interface, it returns the error
+// of the request's context.
+func (ctx *Context) Err() error {
+	return ctx.requestContext().Err()
+}
+
+// Value implements the context.Context interface, the string keys are
+// looked up in the user values first, and then in the request's context.
+// The route's parameters are not included, see UserValue.
+func (ctx *Context) Value(key interface{}) interface{} {
+	switch k := key.(type) {
+	case string:
+		if value, ok := ctx.lookupUserValue(k); ok {
+			return value
+		}
+	case contextKey:
+		return ctx
+	}
+
+	return ctx.requestContext().Value(key)
+}
+
+// WithTimeout replaces ctx.Request with a shallow copy whose context
+// is canceled after the timeout elapses, the returned cancel function
+// should be called to release resources once the work is done:
+//
+//	cancel := ctx.WithTimeout(3 * time.Second)
+//	defer cancel()
+//
+//	rows, err := db.QueryContext(ctx, query)
+func (ctx *Context) WithTimeout(timeout time.Duration) context.CancelFunc {
+	c, cancel := context.WithTimeout(ctx.requestContext(), timeout)
+	ctx.setRequestContext(c)
+	return cancel
+}
+
+// WithValue replaces ctx.Request with a shallow copy whose context
+// carries the value, it is visible to the downstream libraries that
+// receive ctx.Request.Context() or ctx, see context.WithValue.
+func (ctx *Context) WithValue(key, value interface{}) {
+	ctx.setRequestContext(context.WithValue(ctx.requestContext(), key, value))
+}
+
+// setRequestContext replaces ctx.Request with a shallow copy with the
+// given context, an empty request is used if ctx has no request.
+func (ctx *Context) setRequestContext(c context.Context) {
+	req := ctx.Request
+	if req == nil {
+		req = new(http.Request)
+	}
+	ctx.Request = req.WithContext(c)
+}
+
+var errNoRouter = errors.New("no router associated with the context")
+
+// URLFor generates the URL of the named route via the router
+// that is dispatching the current request.
+//
+// See Router.URL.
+func (ctx *Context) URLFor(name string, params ...interface{}) (string, error) {
+	if ctx.router == nil {
+		return "", errNoRouter
+	}
+
+	return ctx.router.URL(name, params...)
+}
+
+// Logger returns a request-scoped logger derived from the server's
+// logger, the following fields are attached to every entry:
+//
+//	request_id - the request ID, omitted if empty, see Context.RequestID
+//	method     - the request method
+//	path       - the request path
+//	route      - the matched route's path, omitted if not routed
+//
+// See NewFieldLogger for how the server's logger is adapted, the
+// default logger is used if ctx is not associated with a server.
+func (ctx *Context) Logger() FieldLogger {
+	if ctx.logger == nil {
+		fields := Fields{}
+		if ctx.Request != nil {
+			fields["method"] = ctx.Request.Method
+			fields["path"] = ctx.Request.URL.Path
+		}
+		if id := ctx.RequestID(); id != "" {
+			fields["request_id"] = id
+		}
+		if ctx.route != "" {
+			fields["route"] = ctx.route
+		}
+		ctx.logger = NewFieldLogger(ctx.serverLogger()).WithFields(fields)
+	}
+
+	return ctx.logger
+}
+
+// serverLogger returns the server's logger, or the default logger if
+// ctx is not associated with a server, such as the router is served
+// via Router.ServeHTTP.
+func (ctx *Context) serverLogger() Logger {
+	if ctx.server == nil || ctx.server.logger == nil {
+		return defaultLogger
+	}
+
 	return ctx.server.logger
 }
 
+// Route returns the path of the matched route, such as "/users/:id",
+// an empty string would be returned if the request is not routed.
+func (ctx *Context) Route() string {
+	return ctx.route
+}
+
+// setRoute sets the path of the matched route.
+func (ctx *Context) setRoute(route string) {
+	ctx.route = route
+	// the request-scoped logger should be rebuilt with the route.
+	ctx.logger = nil
+}
+
 // SetServer for testing, do not use it in other places.
 func (ctx *Context) SetServer(server *Server) {
 	ctx.server = server
@@ -224,7 +445,9 @@ func (ctx *Context) JSON(code int, v interface{}) {
 	data, err := json.Marshal(v)
 	if err != nil {
 		ctx.Logger().Errorf("JSON error: %s\n", err)
-		ctx.Response.WriteHeader(http.StatusInternalServerError)
+		if !ctx.Written() {
+			ctx.Response.WriteHeader(http.StatusInternalServerError)
+		}
 		return
 	}
 	ctx.SetContentType(MIMEJSON)
@@ -237,7 +460,9 @@ func (ctx *Context) XML(code int, v interface{}, headers ...string) {
 	data, err := xml.Marshal(v)
 	if err != nil {
 		ctx.Logger().Errorf("XML error: %s\n", err)
-		ctx.Response.WriteHeader(http.StatusInternalServerError)
+		if !ctx.Written() {
+			ctx.Response.WriteHeader(http.StatusInternalServerError)
+		}
 		return
 	}
 
@@ -254,3 +479,47 @@ func (ctx *Context) XML(code int, v interface{}, headers ...string) {
 	ctx.Response.WriteHeader(code)
 	ctx.Response.Write(bytes)
 }
+
+var errNoTemplates = errors.New("no templates associated with the router")
+
+// Render renders the template of the given name with the layout via the
+// router's templates manager, the name is relative to Templates.Path
+// without suffix, such as:
+//
+//	ctx.Render(200, "main", "users/index", data)
+//
+// The template is executed into a buffer first, the request is answered
+// with 500 Internal Server Error if failed to render the template.
+//
+// The "csrfToken" function of templates returns the CSRF token of ctx,
+// such as {{csrfToken}}.
+func (ctx *Context) Render(code int, layout, name string, data interface{}) {
+	if err := ctx.render(code, layout, name, data); err != nil {
+		ctx.Logger().Errorf("render error: %s\n", err)
+		if !ctx.Written() {
+			ctx.Response.WriteHeader(http.StatusInternalServerError)
+		}
+	}
+}
+
+func (ctx *Context) render(code int, layout, name string, data interface{}) error {
+	if ctx.router == nil || ctx.router.templates == nil {
+		return errNoTemplates
+	}
+
+	tmpl, err := ctx.router.templates.Render(layout, name)
+	if err != nil {
+		return err
+	}
+	ctx.router.templates.bindContextFuncs(tmpl, ctx)
+
+	buf := &bytes.Buffer{}
+	if err = tmpl.Execute(buf, data); err != nil {
+		return err
+	}
+
+	ctx.SetContentType(MIMEHTML)
+	ctx.Response.WriteHeader(code)
+	_, err = buf.WriteTo(ctx.Response)
+	return err
+}
@@ -6,12 +6,19 @@ package gem
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"encoding/xml"
 	"fmt"
 	"net/http"
+	"net/http/httptest"
 	"reflect"
+	"strings"
 	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/go-gem/log"
 )
 
 func TestContext_UserValue(t *testing.T) {
@@ -270,10 +277,63 @@ func TestContext_XML(t *testing.T) {
 }
 
 func TestContext_Logger(t *testing.T) {
+	buf := &bytes.Buffer{}
 	srv := New("")
+	srv.SetLogger(log.New(buf, 0, log.LevelAll))
+
+	router := NewRouter()
+	router.GET("/users/:name", func(ctx *Context) {
+		ctx.Logger().WithField("user", ctx.Param("name")).Info("hello")
+		if ctx.Logger() != ctx.Logger() {
+			t.Error("the logger should be created once per request")
+		}
+	})
+
+	req, _ := http.NewRequest(MethodGet, "/users/foo", nil)
+	req.Header.Set("X-Request-Id", "abc")
+	router.Handler().Handle(newContext(srv, &mockResponseWriter{}, req))
+
+	want := "hello method=GET path=/users/foo request_id=abc route=/users/:name user=foo\n"
+	if !strings.HasSuffix(buf.String(), want) {
+		t.Errorf("expected log %q, got %q", want, buf.String())
+	}
+
+	buf.Reset()
 	ctx := &Context{server: srv}
-	if ctx.Logger() != srv.logger {
-		t.Error("failed to get logger")
+	ctx.Logger().Info("no request")
+	if !strings.HasSuffix(buf.String(), "no request\n") {
+		t.Errorf("expected log %q, got %q", "no request\n", buf.String())
+	}
+}
+
+// preRoutingLoggerMiddleware logs before the request is routed.
+type preRoutingLoggerMiddleware struct{}
+
+func (m preRoutingLoggerMiddleware) Wrap(next Handler) Handler {
+	return HandlerFunc(func(ctx *Context) {
+		ctx.Logger().Info("before")
+		next.Handle(ctx)
+	})
+}
+
+func TestContext_LoggerBeforeRouting(t *testing.T) {
+	buf := &bytes.Buffer{}
+	srv := New("")
+	srv.SetLogger(log.New(buf, 0, log.LevelAll))
+
+	router := NewRouter()
+	router.Use(preRoutingLoggerMiddleware{})
+	router.GET("/users/:name", func(ctx *Context) {
+		ctx.Logger().Info("after")
+	})
+
+	req, _ := http.NewRequest(MethodGet, "/users/foo", nil)
+	router.Handler().Handle(newContext(srv, &mockResponseWriter{}, req))
+
+	// the logger is rebuilt with the route once routed.
+	want := "before method=GET path=/users/foo\nafter method=GET path=/users/foo route=/users/:name\n"
+	if !strings.HasSuffix(buf.String(), want) {
+		t.Errorf("expected log %q, got %q", want, buf.String())
 	}
 }
 
@@ -373,3 +433,306 @@ func TestContext_SetServer(t *testing.T) {
 		t.Error("failed to set server")
 	}
 }
+
+func TestContext_URLFor(t *testing.T) {
+	ctx := &Context{}
+	if _, err := ctx.URLFor("user", "foo"); err != errNoRouter {
+		t.Errorf("expected error %q, got %q", errNoRouter, err)
+	}
+
+	router := NewRouter()
+	router.GET("/users/:name", func(ctx *Context) {
+		url, err := ctx.URLFor("user", "bar")
+		if err != nil {
+			t.Fatal(err)
+		}
+		ctx.Redirect(url, http.StatusFound)
+	}, NewNamedHandlerOption("user"))
+
+	resp := httptest.NewRecorder()
+	req, _ := http.NewRequest(MethodGet, "/users/foo", nil)
+	router.Handler().Handle(newContext(nil, resp, req))
+	if location := resp.Header().Get("Location"); location != "/users/bar" {
+		t.Errorf("expected location %q, got %q", "/users/bar", location)
+	}
+}
+
+type testContextKey string
+
+func TestContext_Context(t *testing.T) {
+	parent, cancel := context.WithCancel(context.Background())
+	req := httptest.NewRequest(MethodGet, "/", nil).WithContext(context.WithValue(parent, testContextKey("foo"), "bar"))
+	ctx := &Context{Request: req}
+	ctx.SetUserValue("name", "gem")
+	ctx.setParam("id", "1")
+
+	if _, ok := ctx.Deadline(); ok {
+		t.Error("expected no deadline")
+	}
+	if ctx.Err() != nil {
+		t.Errorf("expected nil error, got %s", ctx.Err())
+	}
+	if ctx.Value("name") != "gem" || ctx.Value(testContextKey("foo")) != "bar" {
+		t.Errorf("unexpected values %v %v", ctx.Value("name"), ctx.Value(testContextKey("foo")))
+	}
+	if ctx.Value("id") != nil {
+		t.Errorf("expected the route's parameters to be excluded, got %v", ctx.Value("id"))
+	}
+	if c, ok := ctx.Value(contextKey{}).(*Context); !ok || c != ctx {
+		t.Error("expected the Context itself")
+	}
+
+	// the derived context should be canceled along with the request.
+	child, childCancel := context.WithCancel(ctx)
+	defer childCancel()
+	cancel()
+	select {
+	case <-ctx.Done():
+	case <-time.After(time.Second):
+		t.Fatal("expected the context to be canceled")
+	}
+	select {
+	case <-child.Done():
+	case <-time.After(time.Second):
+		t.Fatal("expected the derived context to be canceled")
+	}
+	if ctx.Err() != context.Canceled {
+		t.Errorf("expected error %q, got %v", context.Canceled, ctx.Err())
+	}
+
+	ctx = &Context{}
+	if ctx.Done() != nil || ctx.Err() != nil || ctx.Value("name") != nil {
+		t.Error("expected the background context if the request is absent")
+	}
+}
+
+func TestContext_WithTimeout(t *testing.T) {
+	req := httptest.NewRequest(MethodGet, "/", nil)
+	ctx := &Context{Request: req}
+	cancel := ctx.WithTimeout(time.Millisecond)
+	defer cancel()
+
+	if ctx.Request == req {
+		t.Error("expected the request to be replaced")
+	}
+	if _, ok := ctx.Deadline(); !ok {
+		t.Error("expected the deadline")
+	}
+	select {
+	case <-ctx.Done():
+	case <-time.After(time.Second):
+		t.Fatal("expected the context to be timed out")
+	}
+	if ctx.Err() != context.DeadlineExceeded {
+		t.Errorf("expected error %q, got %v", context.DeadlineExceeded, ctx.Err())
+	}
+
+	ctx = &Context{}
+	cancel = ctx.WithTimeout(time.Second)
+	defer cancel()
+	if _, ok := ctx.Deadline(); !ok {
+		t.Error("expected the deadline if the request is absent")
+	}
+}
+
+func TestContext_WithValue(t *testing.T) {
+	req := httptest.NewRequest(MethodGet, "/", nil)
+	ctx := &Context{Request: req}
+	ctx.WithValue(testContextKey("foo"), "bar")
+
+	if ctx.Request == req {
+		t.Error("expected the request to be replaced")
+	}
+	if ctx.Request.Context().Value(testContextKey("foo")) != "bar" || ctx.Value(testContextKey("foo")) != "bar" {
+		t.Error("expected the value in the request's context")
+	}
+
+	// the association with request should be preserved.
+	ctx.httpRequest()
+	ctx.WithValue(testContextKey("bar"), "baz")
+	if c, ok := ContextFromRequest(ctx.Request); !ok || c != ctx {
+		t.Error("expected the Context associated with the request")
+	}
+
+	ctx = &Context{}
+	ctx.WithValue(testContextKey("foo"), "bar")
+	if ctx.Value(testContextKey("foo")) != "bar" {
+		t.Error("expected the value if the request is absent")
+	}
+}
+
+func TestContextPool(t *testing.T) {
+	srv := New("")
+	srv.SetReuseContext(true)
+	req := httptest.NewRequest(MethodGet, "/", nil)
+	ctx := srv.acquireContext(httptest.NewRecorder(), req)
+	ctx.router = NewRouter()
+	ctx.route = "/users/:name"
+	ctx.SetRequestID("foo")
+	ctx.setParam("name", "gem")
+	ctx.SetUserValue("user", "gem")
+	ctx.Logger()
+	ctx.ResponseWriter().Before(func(w *ResponseWriter) {})
+	ctx.Write([]byte("foo"))
+	srv.releaseContext(ctx)
+
+	w := httptest.NewRecorder()
+	req2 := httptest.NewRequest(MethodPost, "/bar", nil)
+	ctx.reset(w, req2)
+	if ctx.server != srv || ctx.router != nil || ctx.route != "" || ctx.RequestID() != "" || ctx.logger != nil {
+		t.Errorf("unexpected context state %+v", ctx)
+	}
+	if len(ctx.Params()) != 0 || ctx.UserValue("user") != nil || cap(*ctx.userValue) == 0 {
+		t.Error("expected the parameters and user values to be reset with storage reused")
+	}
+	rw := ctx.ResponseWriter()
+	if rw != &ctx.response || rw.Unwrap() != w || rw.Written() || rw.Status() != 0 || rw.Size() != 0 || len(rw.before) != 0 {
+		t.Errorf("unexpected response writer state %+v", rw)
+	}
+	if ctx.Request != req2 {
+		t.Error("expected the request to be replaced")
+	}
+}
+
+func TestContextRetained(t *testing.T) {
+	values := make(chan interface{}, 1)
+	release := make(chan struct{})
+	srv := New("")
+	srv.init(HandlerFunc(func(ctx *Context) {
+		ctx.SetUserValue("user", ctx.Request.URL.Query().Get("user"))
+		if ctx.UserValue("user") != "alice" {
+			return
+		}
+		go func(c context.Context) {
+			<-release
+			values <- c.Value("user")
+		}(ctx)
+	}))
+
+	srv.Server.Handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(MethodGet, "/?user=alice", nil))
+	srv.Server.Handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(MethodGet, "/?user=bob", nil))
+	close(release)
+	if value := <-values; value != "alice" {
+		t.Errorf("expected the retained Context to refer to its own request, got %v", value)
+	}
+}
+
+func TestContextZeroAllocs(t *testing.T) {
+	srv := New("")
+	srv.SetReuseContext(true)
+	srv.init(HandlerFunc(func(ctx *Context) {
+		ctx.SetUserValue("user", "gem")
+		_ = ctx.UserValue("user")
+	}))
+	w := new(mockResponseWriter)
+	req := httptest.NewRequest(MethodGet, "/", nil)
+	srv.Server.Handler.ServeHTTP(w, req)
+
+	allocs := testing.AllocsPerRun(100, func() {
+		srv.Server.Handler.ServeHTTP(w, req)
+	})
+	if allocs > 0 {
+		t.Errorf("expected zero allocations, got %v", allocs)
+	}
+}
+
+func BenchmarkContextUserValue(b *testing.B) {
+	srv := New("")
+	srv.SetReuseContext(true)
+	srv.init(HandlerFunc(func(ctx *Context) {
+		ctx.SetUserValue("user", "gem")
+		_ = ctx.UserValue("user")
+	}))
+	w := new(mockResponseWriter)
+	req := httptest.NewRequest(MethodGet, "/", nil)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		srv.Server.Handler.ServeHTTP(w, req)
+	}
+}
+
+func TestContext_Render(t *testing.T) {
+	ts := NewTemplates(testPath)
+	if err := ts.SetLayout("main"); err != nil {
+		t.Fatal(err)
+	}
+	router := NewRouter()
+	router.SetTemplates(ts)
+
+	resp := httptest.NewRecorder()
+	ctx := &Context{Response: resp, router: router, server: New("")}
+	ctx.Render(201, "main", "index", nil)
+	if resp.Code != 201 {
+		t.Errorf("expected status code %d, got %d", 201, resp.Code)
+	}
+	if resp.Header().Get("Content-Type") != MIMEHTML {
+		t.Errorf("expected Content-Type %q, got %q", MIMEHTML, resp.Header().Get("Content-Type"))
+	}
+	html := `<html><head></head><body>hello wolrd<body></html>`
+	if resp.Body.String() != html {
+		t.Errorf("expected html %q, got %q", html, resp.Body.String())
+	}
+
+	tests := []struct {
+		router *Router
+		layout string
+		name   string
+	}{
+		{nil, "main", "index"},
+		{NewRouter(), "main", "index"},
+		{router, "nonexistentLayout", "index"},
+		{router, "main", "nonexistent"},
+		{router, "main", "broken"},
+	}
+	for _, test := range tests {
+		resp = httptest.NewRecorder()
+		ctx = &Context{Response: resp, router: test.router, server: New("")}
+		ctx.Render(200, test.layout, test.name, []int{})
+		if resp.Code != http.StatusInternalServerError {
+			t.Errorf("expected status code %d for %s, got %d", http.StatusInternalServerError, test.name, resp.Code)
+		}
+		if resp.Body.Len() != 0 {
+			t.Errorf("expected empty body for %s, got %q", test.name, resp.Body.String())
+		}
+	}
+}
+
+func TestContext_RenderCSRFToken(t *testing.T) {
+	ts := NewTemplates("")
+	ts.FS = fstest.MapFS{
+		"layouts/main.html": {Data: []byte(`<form>{{block "body" .}}{{end}}</form>`)},
+		"form.html":         {Data: []byte(`{{define "body"}}<input value="{{csrfToken}}">{{end}}`)},
+	}
+	if err := ts.SetLayout("main"); err != nil {
+		t.Fatal(err)
+	}
+	router := NewRouter()
+	router.SetTemplates(ts)
+
+	// both of the parsed and compiled templates are bound to the rendering context.
+	for _, compile := range []bool{false, true} {
+		if compile {
+			if err := ts.Compile(); err != nil {
+				t.Fatal(err)
+			}
+		}
+		for _, token := range []string{"foo", "bar"} {
+			resp := httptest.NewRecorder()
+			ctx := &Context{Response: resp, router: router, server: New("")}
+			ctx.SetUserValue(CSRFTokenKey, token)
+			ctx.Render(200, "main", "form", nil)
+
+			want := `<form><input value="` + token + `"></form>`
+			if resp.Body.String() != want {
+				t.Errorf("expected html %q, got %q", want, resp.Body.String())
+			}
+		}
+	}
+
+	router.SetTemplates(nil)
+	if router.templates != nil {
+		t.Error("failed to unset the templates")
+	}
+}
@@ -5,7 +5,7 @@
 /*
 Package gem is a high performance web framework, it is friendly to REST APIs.
 
-Note: This package requires go1.8 or above.
+Note: This package requires go1.16 or above.
 
 Features
 
@@ -97,6 +97,14 @@ We take logrus as example to show that how to set and use logger.
 			ctx.Logger().Error("error")
 	})
 
+Context.Logger returns a request-scoped FieldLogger, the request ID, method, path and
+route are attached to every entry, more fields can be attached via WithField and WithFields:
+
+	ctx.Logger().WithField("user", name).Info("user logged in")
+
+The logrus-style loggers are adapted automatically, the other loggers append the fields to
+the messages in "key=value" form.
+
 Static Files
 
 example that serve static files:
@@ -169,6 +177,342 @@ The router is friendly to REST APIs.
 	    ctx.JSON(200, msg)
 	}
 
+Route Parameters
+
+The values of route's parameters are stored separately from user values, Context provides
+typed accessors for them:
+
+	router.GET("/users/:id", func(ctx *gem.Context) {
+	    id, err := ctx.ParamInt64("id")
+	    if err != nil {
+		ctx.JSON(400, err.Error())
+		return
+	    }
+
+	    ctx.JSON(200, userProfileByID(id))
+	})
+
+The value of parameter can be constrained by one of the built-in types int, uint, alpha,
+alnum and uuid, or a regular expression, the request that does not satisfy the constraint
+would be treated as not found:
+
+	router.GET("/users/:id<int>", userProfile)
+	router.GET("/posts/:slug<[a-z0-9-]+>", post)
+	router.GET("/archives/:date<\\d{4}-\\d{2}-\\d{2}>", archive)
+
+Binding
+
+Context.Bind decodes the request body into a struct by the Content-Type header, JSON, XML,
+urlencoded form and multipart form are supported, BindQuery, BindParams and BindHeader
+map the URL query, route's parameters and headers by the struct tags:
+
+	type CreateUser struct {
+	    Name   string                `json:"name" form:"name"`
+	    Avatar *multipart.FileHeader `form:"avatar"`
+	}
+
+	router.POST("/users", func(ctx *gem.Context) {
+	    var form CreateUser
+	    if err := ctx.Bind(&form); err != nil {
+		ctx.JSON(400, err.Error())
+		return
+	    }
+
+	    // add user
+	})
+
+The nested struct and pointer to struct fields without tag are mapped recursively, the nil
+pointer is allocated only if any of its fields was set.
+
+Validation
+
+The bound struct is validated by the "validate" tag, the rules are required, omitempty,
+min, max, len, email, oneof and regexp. Context.Bind validates the struct automatically,
+and returns a ValidationErrors which can be rendered as response directly:
+
+	type CreateUser struct {
+	    Name  string `json:"name" validate:"required,max=32"`
+	    Email string `json:"email" validate:"required,email"`
+	    Role  string `json:"role" validate:"oneof=admin member"`
+	}
+
+	router.POST("/users", func(ctx *gem.Context) {
+	    var form CreateUser
+	    if err := ctx.Bind(&form); err != nil {
+		if errs, ok := err.(gem.ValidationErrors); ok {
+		    ctx.JSON(422, errs)
+		    return
+		}
+		ctx.JSON(400, err.Error())
+		return
+	    }
+
+	    // add user
+	})
+
+The other data can be validated by gem.Validate.
+
+Error Handling
+
+Handlers that return an error are registered via gem.E, the error is handled by Router.ErrorHandler,
+or DefaultErrorHandler which answers the request in JSON, XML or HTML according to the Accept
+header. HTTPError carries the status code, ValidationErrors is answered with 422, ParamError,
+BindError and malformed request body with 400, and the other errors with 500 without exposing
+their messages:
+
+	router.GET("/users/:id", gem.E(func(ctx *gem.Context) error {
+	    id, err := ctx.ParamInt64("id")
+	    if err != nil {
+		return err
+	    }
+
+	    user, ok := userByID(id)
+	    if !ok {
+		return &gem.HTTPError{Status: 404, Code: "user_not_found", Message: "the user does not exist"}
+	    }
+
+	    ctx.JSON(200, user)
+	    return nil
+	}))
+
+the errors are rendered in the problem details format of RFC 7807 if Router.ProblemDetails is enabled:
+
+	router.ProblemDetails = true
+
+Cancellation and Deadlines
+
+Context implements the context.Context interface, the deadline, cancellation signal and values are
+backed by the request's context, and the string keys are looked up in the user values first, so that
+it can be passed to the downstream libraries directly:
+
+	router.GET("/users", gem.E(func(ctx *gem.Context) error {
+	    cancel := ctx.WithTimeout(3 * time.Second)
+	    defer cancel()
+
+	    // the query is canceled if the client disconnects or the timeout elapses.
+	    rows, err := db.QueryContext(ctx, "SELECT name FROM users")
+	    if err != nil {
+		return err
+	    }
+	    // ...
+	}))
+
+Context.WithValue stores the value into the request's context as well.
+
+Content Negotiation
+
+Context.Negotiate responses data in the media type that is most acceptable by the client
+according to the Accept header, JSON, XML, plain text and HTML are supported, the custom
+renderers can be registered via Router.SetRenderer:
+
+	router.SetRenderer("application/x-yaml", gem.RendererFunc(func(ctx *gem.Context, code int, data interface{}) error {
+	    // write YAML data
+	}))
+
+	router.GET("/users/:name", func(ctx *gem.Context) {
+	    user := getUser(ctx.Param("name"))
+	    // HTML is rendered only if the data is a *gem.View.
+	    ctx.Negotiate(200, &gem.View{Template: tmpl, Data: user})
+	})
+
+The request is answered with 406 Not Acceptable if none of the offers is acceptable.
+
+Templates
+
+Context.Render renders a template with the layout via the templates manager of router,
+which is configured by the "templates" option of Application, the template is executed
+into a buffer first, so that a broken template results in 500 Internal Server Error
+instead of a half-written page:
+
+	router.GET("/users", func(ctx *gem.Context) {
+	    // renders templates/users/index.html with templates/layouts/main.html.
+	    ctx.Render(200, "main", "users/index", users)
+	})
+
+All of the templates are compiled with each layout at Application.Init, so that rendering
+does not read files any more. In development mode, which is enabled by the "development"
+field of "templates" option, the modification times of files are checked via stat on every
+render rather than watched, and the templates are reparsed once the files have been modified.
+
+The templates in "partials" directory are available to every template, and a layout can
+extend another layout via the "extends" field of "templates" option, the blocks can be
+overridden at every level:
+
+	{
+	    "templates": {
+		"layouts": ["base", "admin"],
+		"extends": {"admin": "base"}
+	    }
+	}
+
+	layouts/base.html:  <html>{{template "nav.html" .}}{{block "content" .}}{{end}}</html>
+	layouts/admin.html: {{define "content"}}<aside></aside>{{block "main" .}}{{end}}{{end}}
+	partials/nav.html:  <nav></nav>
+	dashboard.html:     {{define "main"}}dashboard{{end}}
+
+The templates have a set of built-in functions, such as url, asset, date, number, json,
+safeHTML, dict and csrfToken, see NewTemplates for the full list:
+
+	<a href="{{url "user" .User.Name}}">{{title .User.Name}}</a>
+	<link rel="stylesheet" href="{{asset "css/main.css"}}">
+	<span>{{date .User.Created "2006-01-02"}}</span>
+	{{template "card.html" dict "title" .Title "items" .Items}}
+	<input type="hidden" name="csrf_token" value="{{csrfToken}}">
+
+Embedded Files
+
+Templates and assets can be loaded from an fs.FS, such as embed.FS, so that an application
+can be shipped as a single binary:
+
+	//go:embed app.json assets templates
+	var files embed.FS
+
+	app, err := gem.NewApplication("app.json", files)
+
+Router.ServeFS serves files from an fs.FS as well:
+
+	router.ServeFS("/static/*filepath", files)
+
+Server-Sent Events
+
+Context.SSE starts an event stream, the headers are written and each event is flushed immediately,
+the stream is stopped once the client disconnects or the handler returns:
+
+	router.GET("/events", gem.E(func(ctx *gem.Context) error {
+	    stream, err := ctx.SSE()
+	    if err != nil {
+		return err
+	    }
+	    defer stream.Close()
+
+	    // keep the connection alive.
+	    stream.Heartbeat(15 * time.Second)
+
+	    // resume from the last event received by the client.
+	    for _, update := range updatesAfter(stream.LastEventID()) {
+		stream.Send(gem.Event{ID: update.ID, Event: "update", Data: update})
+	    }
+
+	    for {
+		select {
+		case <-stream.Done():
+		    return nil
+		case update := <-updates:
+		    if err := stream.Send(gem.Event{ID: update.ID, Event: "update", Data: update}); err != nil {
+			return err
+		    }
+		}
+	    }
+	}))
+
+WebSocket
+
+Router.WebSocket registers a handler that upgrades the connection to WebSocket, the text and binary
+messages are read and written via the WebSocketConn, the pings are replied automatically:
+
+	router.WebSocketUpgrader = &gem.WebSocketUpgrader{
+	    // the requests from other origins are rejected by default.
+	    CheckOrigin: func(r *http.Request) bool { return r.Header.Get("Origin") == "https://example.com" },
+	    ReadLimit:   64 << 10,
+	}
+
+	router.WebSocket("/echo", func(ctx *gem.Context, conn *gem.WebSocketConn) {
+	    for {
+		messageType, p, err := conn.ReadMessage()
+		if err != nil {
+		    // *gem.CloseError is returned once the client closed the connection.
+		    return
+		}
+		if err = conn.WriteMessage(messageType, p); err != nil {
+		    return
+		}
+	    }
+	})
+
+Context.Upgrade upgrades the connection in a regular handler, the returned connection should be closed
+by the handler.
+
+Graceful Shutdown
+
+Server.Run acts like ListenAndServe, except that it stops accepting new connections once
+received SIGINT or SIGTERM, and waits for the in-flight requests with a deadline, the
+shutdown callbacks are invoked after that:
+
+	srv := gem.New(":8080")
+	srv.SetShutdownTimeout(10 * time.Second)
+	srv.SetShutdownCallback(func() {
+	    app.Close()
+	})
+
+	log.Println(srv.Run(router.Handler()))
+
+Application
+
+Application.Run boots an application from the JSON configuration, it initializes the
+application and controllers, runs a server configured by the "server" option, and invokes
+the close callbacks once the server has been shut down gracefully, or failed to start:
+
+	{
+	    "server": {
+		"addr": ":8080",
+		"read_timeout": "5s",
+		"write_timeout": "10s",
+		"shutdown_timeout": "30s",
+		"logger": {"output": "stderr", "levels": ["info", "error", "fatal"]}
+	    }
+	}
+
+	app, err := gem.NewApplication("app.json")
+	if err != nil {
+	    log.Fatal(err)
+	}
+	log.Println(app.Run())
+
+The server serves HTTPS if both of "cert_file" and "key_file" are specified, an error is
+returned if only one of them is specified.
+
+net/http Compatibility
+
+The standard net/http handlers and middlewares can be reused via WrapHTTPHandler, WrapHTTPMiddleware
+and Router.Mount, the Context is associated with the request, so that the route's parameters and user
+values are accessible via ContextFromRequest:
+
+	router.GET("/metrics", gem.WrapHTTPHandler(promhttp.Handler()))
+	router.Use(gem.WrapHTTPMiddleware(handlers.CompressHandler))
+
+	// the prefix is stripped from the request path.
+	router.Mount("/admin", adminRouter)
+
+Router implements the http.Handler interface as well, it can be mounted in http.ServeMux:
+
+	mux.Handle("/api/", http.StripPrefix("/api", router))
+
+Route Groups
+
+Routes that share the same path prefix and middlewares can be declared via Router.Group,
+groups can be nested:
+
+	api := router.Group("/api", gem.NewHandlerOption(&Auth{}))
+	v1 := api.Group("/v1")
+
+	// GET /api/v1/users
+	v1.GET("/users", func(ctx *gem.Context) {
+	    ctx.JSON(200, userlist)
+	})
+
+Named Routes
+
+A route can be named via HandlerOption, and then its URL can be generated by Router.URL,
+Context.URLFor or the "url" function of application's templates:
+
+	router.GET("/users/:name", userProfile, gem.NewNamedHandlerOption("user"))
+
+	// "/users/foo"
+	url, err := router.URL("user", "foo")
+
+	// in template
+	<a href="{{url "user" .Name}}">{{.Name}}</a>
+
 HTTP2 Server Push
 
 see https://github.com/go-gem/examples/tree/master/http2
@@ -217,6 +561,23 @@ we can also register the middleware for specific handler via HandlerOption(https
 
 	router.GET("/specific", specificHandler, &gem.HandlerOption{Middlewares:[]gem.Middleware{&Debug{}}})
 
+The Context.Response is a ResponseWriter(https://godoc.org/github.com/go-gem/gem#ResponseWriter) that records the
+status code and size of response, the middlewares can inspect the response after the handler returned, or modify
+the header right before it is written:
+
+	func (d *Debug) Wrap(next gem.Handler) gem.Handler {
+		return gem.HandlerFunc(func(ctx *gem.Context) {
+			w := ctx.ResponseWriter()
+			w.Before(func(w *gem.ResponseWriter) {
+				w.Header().Set("X-Debug", "true")
+			})
+
+			next.Handle(ctx)
+
+			log.Println(ctx.Request.URL, w.Status(), w.Size(), w.Written())
+		})
+	}
+
 Gem also provides some frequently used middlewares, such as:
 
 1. CSRF Middleware - Cross-Site Request Forgery protection - https://github.com/go-gem/middleware-csrf
@@ -232,6 +593,59 @@ Gem also provides some frequently used middlewares, such as:
 6. Request Body Limit Middleware - limit request body maximum size - https://github.com/go-gem/middleware-body-limit
 
 
+Access Log
+
+AccessLog(https://godoc.org/github.com/go-gem/gem#AccessLog) is a built-in middleware that logs the method, URI,
+status code, response size and latency of each request, in Common Log Format, Combined Log Format, JSON or a
+custom text/template:
+
+	accessLog := gem.NewAccessLog(gem.CombinedLogFormat)
+
+	// write to a file instead of the server's logger.
+	accessLog.Output = file
+
+	// skip the health check and the static files.
+	accessLog.SkipPaths = []string{"/health", "/assets/*"}
+
+	router.Use(accessLog)
+
+the custom format is executed with AccessLogEntry(https://godoc.org/github.com/go-gem/gem#AccessLogEntry):
+
+	gem.NewAccessLog(`{{.Method}} {{.Route}} {{.Status}} {{.Latency}}`)
+
+
+Recovery
+
+Recovery(https://godoc.org/github.com/go-gem/gem#Recovery) is a built-in middleware that recovers from panics
+in handlers and middlewares, logs the stack trace via the Context.Logger, and answers the request with 500 Internal
+Server Error in JSON or HTML according to the Accept header. Unlike Router.PanicHandler, it also catches the
+panics of router-level middlewares, so it should be registered first:
+
+	recovery := gem.NewRecovery()
+
+	// render the panic and stack trace, do not enable it in production.
+	recovery.Debug = true
+
+	router.Use(recovery)
+
+
+Request ID
+
+RequestID(https://godoc.org/github.com/go-gem/gem#RequestID) is a built-in middleware that honors the incoming
+X-Request-Id header, otherwise generates a random UUID, the request ID is echoed in the response header, and
+attached to the entries of Context.Logger and AccessLogEntry:
+
+	router.Use(gem.NewRequestID())
+
+	router.GET("/", func(ctx *gem.Context) {
+		ctx.Logger().Info(ctx.RequestID())
+	})
+
+the header and generator are configurable:
+
+	router.Use(&gem.RequestID{Header: "X-Trace-Id", Generator: newTraceID})
+
+
 Share data between middlewares
 
 Context provides two useful methods: `SetUserValue` and `UserValue` to share data between middlewares.
@@ -241,5 +655,9 @@ Context provides two useful methods: `SetUserValue` and `UserValue` to share dat
 
 	// Get data from context in other middleware or handler
 	ctx.UserValue("name")
+
+Note: the Context can be reused by the Server to avoid allocations via Server.SetReuseContext, the reused
+Context must not be retained after the handler returned, pass ctx.Request.Context() instead of ctx to the
+goroutines and libraries that outlive the request.
 */
 package gem
@@ -0,0 +1,255 @@
+// Copyright 2016 The Gem Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file.
+
+package gem
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"html/template"
+	"net/http"
+)
+
+// Problem details media types, see RFC 7807.
+const (
+	MIMEProblemJSON = "application/problem+json"
+	MIMEProblemXML  = "application/problem+xml"
+)
+
+// The ErrorHandlerFunc type is an adapter to allow the use of ordinary
+// functions that return error as HTTP handlers, the returned error is
+// handled by Context.HandleError.
+type ErrorHandlerFunc func(*Context) error
+
+// Handle calls f(ctx), and handles the returned error.
+func (f ErrorHandlerFunc) Handle(ctx *Context) {
+	if err := f(ctx); err != nil {
+		ctx.HandleError(err)
+	}
+}
+
+// E converts the function that returns error to HandlerFunc, so that
+// it can be registered by the registration methods of Router and Group:
+//
+//	router.GET("/users/:id", gem.E(func(ctx *gem.Context) error {
+//		// ...
+//		return nil
+//	}))
+func E(f func(*Context) error) HandlerFunc {
+	return ErrorHandlerFunc(f).Handle
+}
+
+// NewHTTPError returns a HTTPError with the given status code and message,
+// the message defaults to the status text if empty.
+func NewHTTPError(status int, message string) *HTTPError {
+	if message == "" {
+		message = http.StatusText(status)
+	}
+
+	return &HTTPError{Status: status, Message: message}
+}
+
+// HTTPError is an error that carries the status code of response, it
+// can be returned by handlers to answer the request with the status
+// code, such as:
+//
+//	router.GET("/users/:name", gem.E(func(ctx *gem.Context) error {
+//		user, ok := users[ctx.Param("name")]
+//		if !ok {
+//			return &gem.HTTPError{
+//				Status:  404,
+//				Code:    "user_not_found",
+//				Message: "the user does not exist",
+//			}
+//		}
+//		ctx.JSON(200, user)
+//		return nil
+//	}))
+type HTTPError struct {
+	XMLName xml.Name `json:"-" xml:"error"`
+
+	// Status is the status code of response.
+	Status int `json:"status" xml:"status,attr"`
+
+	// Code is an application-specific error code.
+	Code string `json:"code,omitempty" xml:"code,attr,omitempty"`
+
+	// Message is a human-readable explanation.
+	Message string `json:"message" xml:"message"`
+
+	// Details is rendered as-is, such as ValidationErrors.
+	Details interface{} `json:"details,omitempty" xml:"details,omitempty"`
+
+	// Err is the internal cause, it is logged but not exposed
+	// to the client.
+	Err error `json:"-" xml:"-"`
+}
+
+func (e *HTTPError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("%d %s: %s", e.Status, e.Message, e.Err)
+	}
+
+	return fmt.Sprintf("%d %s", e.Status, e.Message)
+}
+
+// Unwrap returns the internal cause.
+func (e *HTTPError) Unwrap() error {
+	return e.Err
+}
+
+// HandleError handles the error via Router.ErrorHandler, or
+// DefaultErrorHandler if it is not set.
+func (ctx *Context) HandleError(err error) {
+	if ctx.router != nil && ctx.router.ErrorHandler != nil {
+		ctx.router.ErrorHandler(ctx, err)
+		return
+	}
+
+	DefaultErrorHandler(ctx, err)
+}
+
+// toHTTPError converts err to HTTPError:
+//
+//	*HTTPError        - as-is, the invalid Status is replaced with 500
+//	ValidationErrors  - 422 Unprocessable Entity, the errors are the Details
+//	*ParamError       - 400 Bad Request
+//	*BindError        - 400 Bad Request
+//	malformed body    - 400 Bad Request, the syntax and type errors of JSON and XML,
+//	                    and the empty or truncated body
+//	the others        - 500 Internal Server Error, the message is not exposed
+func toHTTPError(err error) *HTTPError {
+	var httpErr *HTTPError
+	var validationErrs ValidationErrors
+	var paramErr *ParamError
+	var bindErr *BindError
+	var jsonSyntaxErr *json.SyntaxError
+	var jsonTypeErr *json.UnmarshalTypeError
+	var xmlSyntaxErr *xml.SyntaxError
+
+	switch {
+	case errors.As(err, &httpErr):
+		if httpErr.Status < 100 || httpErr.Status > 999 {
+			// the invalid status code would make WriteHeader panic.
+			e := *httpErr
+			e.Status = http.StatusInternalServerError
+			return &e
+		}
+		return httpErr
+	case errors.As(err, &validationErrs):
+		e := NewHTTPError(http.StatusUnprocessableEntity, "")
+		e.Details, e.Err = validationErrs, err
+		return e
+	case errors.As(err, &paramErr), errors.As(err, &bindErr):
+		e := NewHTTPError(http.StatusBadRequest, err.Error())
+		e.Err = err
+		return e
+	case errors.As(err, &jsonSyntaxErr), errors.As(err, &jsonTypeErr), errors.As(err, &xmlSyntaxErr):
+		e := NewHTTPError(http.StatusBadRequest, "malformed request body: "+err.Error())
+		e.Err = err
+		return e
+	case err == errUnsupportedMediaType:
+		return NewHTTPError(http.StatusUnsupportedMediaType, err.Error())
+	case err == errEmptyRequestBody:
+		return NewHTTPError(http.StatusBadRequest, err.Error())
+	case err == errTruncatedRequestBody:
+		e := NewHTTPError(http.StatusBadRequest, "malformed request body: "+err.Error())
+		e.Err = err
+		return e
+	}
+
+	e := NewHTTPError(http.StatusInternalServerError, "")
+	e.Err = err
+	return e
+}
+
+// problem is the problem details of RFC 7807.
+type problem struct {
+	XMLName  xml.Name    `json:"-" xml:"urn:ietf:rfc:7807 problem"`
+	Title    string      `json:"title" xml:"title"`
+	Status   int         `json:"status" xml:"status"`
+	Detail   string      `json:"detail,omitempty" xml:"detail,omitempty"`
+	Instance string      `json:"instance,omitempty" xml:"instance,omitempty"`
+	Code     string      `json:"code,omitempty" xml:"code,omitempty"`
+	Errors   interface{} `json:"errors,omitempty" xml:"errors,omitempty"`
+}
+
+// DefaultErrorHandler answers the request with the status code of the
+// error, see HTTPError. The response is rendered in JSON, XML or HTML
+// according to the Accept header, or in the problem details format of
+// RFC 7807 if Router.ProblemDetails is enabled.
+//
+// The server errors are logged via Context.Logger, nothing would be
+// written if the header has been written.
+func DefaultErrorHandler(ctx *Context, err error) {
+	e := toHTTPError(err)
+	if e.Status >= http.StatusInternalServerError {
+		ctx.Logger().Errorf("handler error: %s\n", err)
+	}
+
+	if ctx.Written() {
+		return
+	}
+
+	mediaType := NegotiateContentType(ctx.Request.Header.Get("Accept"), []string{MIMEJSON, MIMEXML, MIMEHTML})
+	ctx.Response.Header().Add("Vary", "Accept")
+
+	var v interface{} = e
+	if ctx.router != nil && ctx.router.ProblemDetails && mediaType != MIMEHTML {
+		v = &problem{
+			Title:    http.StatusText(e.Status),
+			Status:   e.Status,
+			Detail:   e.Message,
+			Instance: ctx.Request.URL.Path,
+			Code:     e.Code,
+			Errors:   e.Details,
+		}
+	}
+
+	var data []byte
+	var contentType string
+	switch mediaType {
+	case MIMEXML:
+		contentType = MIMEXML
+		if _, ok := v.(*problem); ok {
+			contentType = MIMEProblemXML
+		}
+		data, err = xml.Marshal(v)
+		data = append([]byte(xml.Header), data...)
+	case MIMEHTML:
+		buf := &bytes.Buffer{}
+		err = errorTemplate.Execute(buf, e)
+		contentType, data = MIMEHTML+"; charset=utf-8", buf.Bytes()
+	default:
+		contentType = MIMEJSON
+		if _, ok := v.(*problem); ok {
+			contentType = MIMEProblemJSON
+		}
+		data, err = json.Marshal(v)
+	}
+	if err != nil {
+		ctx.Logger().Errorf("error handler error: %s\n", err)
+		http.Error(ctx.Response, e.Message, e.Status)
+		return
+	}
+
+	ctx.SetContentType(contentType)
+	ctx.Response.WriteHeader(e.Status)
+	ctx.Response.Write(data)
+}
+
+var errorTemplate = template.Must(template.New("error").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Status}} {{.Message}}</title>
+</head>
+<body>
+<h1>{{.Status}} {{.Message}}</h1>
+</body>
+</html>
+`))
@@ -0,0 +1,253 @@
+// Copyright 2016 The Gem Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file.
+
+package gem
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-gem/log"
+)
+
+type errorTestForm struct {
+	Name string `json:"name" validate:"required"`
+}
+
+func newErrorTestRouter() *Router {
+	router := NewRouter()
+	router.GET("/http", E(func(ctx *Context) error {
+		return &HTTPError{Status: http.StatusNotFound, Code: "user_not_found", Message: "no such user"}
+	}))
+	router.GET("/internal", E(func(ctx *Context) error {
+		return errors.New("database is down")
+	}))
+	router.GET("/param/:id", E(func(ctx *Context) error {
+		_, err := ctx.ParamInt("id")
+		return err
+	}))
+	router.POST("/validate", E(func(ctx *Context) error {
+		var form errorTestForm
+		return ctx.Bind(&form)
+	}))
+	router.GET("/nostatus", E(func(ctx *Context) error {
+		return &HTTPError{Message: "no status"}
+	}))
+	router.GET("/written", E(func(ctx *Context) error {
+		ctx.HTML(http.StatusOK, "foo")
+		return errors.New("written")
+	}))
+	router.GET("/nil", ErrorHandlerFunc(func(ctx *Context) error {
+		ctx.HTML(http.StatusOK, "ok")
+		return nil
+	}).Handle)
+	return router
+}
+
+func serveError(router *Router, method, target, accept, body string) (*httptest.ResponseRecorder, string) {
+	buf := &bytes.Buffer{}
+	srv := New("")
+	srv.SetLogger(log.New(buf, 0, log.LevelAll))
+
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+	if method == MethodPost {
+		req.Header.Set("Content-Type", MIMEJSON)
+	}
+	w := httptest.NewRecorder()
+	router.Handler().Handle(newContext(srv, NewResponseWriter(w), req))
+	return w, buf.String()
+}
+
+func TestDefaultErrorHandler(t *testing.T) {
+	router := newErrorTestRouter()
+
+	tests := []struct {
+		method, target, body string
+		code                 int
+		want                 HTTPError
+	}{
+		{MethodGet, "/http", "", http.StatusNotFound, HTTPError{Status: 404, Code: "user_not_found", Message: "no such user"}},
+		{MethodGet, "/internal", "", http.StatusInternalServerError, HTTPError{Status: 500, Message: "Internal Server Error"}},
+		{MethodGet, "/nostatus", "", http.StatusInternalServerError, HTTPError{Status: 500, Message: "no status"}},
+		{MethodGet, "/param/foo", "", http.StatusBadRequest, HTTPError{Status: 400, Message: `invalid value "foo" of parameter "id": invalid syntax`}},
+		{MethodPost, "/validate", "{}", http.StatusUnprocessableEntity, HTTPError{Status: 422, Message: "Unprocessable Entity"}},
+		{MethodPost, "/validate", `{"name": 1}`, http.StatusBadRequest, HTTPError{Status: 400, Message: "malformed request body: json: cannot unmarshal number into Go struct field errorTestForm.name of type string"}},
+		{MethodPost, "/validate", "", http.StatusBadRequest, HTTPError{Status: 400, Message: "empty request body"}},
+		{MethodPost, "/validate", `{"name":`, http.StatusBadRequest, HTTPError{Status: 400, Message: "malformed request body: unexpected end of request body"}},
+	}
+	for _, test := range tests {
+		w, _ := serveError(router, test.method, test.target, "", test.body)
+		if w.Code != test.code {
+			t.Errorf("%s: expected status code %d, got %d", test.target, test.code, w.Code)
+		}
+		if w.Header().Get("Content-Type") != MIMEJSON {
+			t.Errorf("%s: expected content type %q, got %q", test.target, MIMEJSON, w.Header().Get("Content-Type"))
+		}
+		var got HTTPError
+		if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+			t.Fatal(err)
+		}
+		got.Details = nil
+		if got != test.want {
+			t.Errorf("%s: expected error %+v, got %+v", test.target, test.want, got)
+		}
+	}
+
+	w, _ := serveError(router, MethodPost, "/validate", "", "{}")
+	want := `"details":[{"field":"name","rule":"required","message":"name is required"}]`
+	if !strings.Contains(w.Body.String(), want) {
+		t.Errorf("expected body contains %q, got %q", want, w.Body.String())
+	}
+}
+
+func TestDefaultErrorHandlerLogging(t *testing.T) {
+	router := newErrorTestRouter()
+
+	_, logs := serveError(router, MethodGet, "/internal", "", "")
+	if !strings.Contains(logs, "database is down") {
+		t.Errorf("expected server error to be logged, got %q", logs)
+	}
+
+	_, logs = serveError(router, MethodGet, "/http", "", "")
+	if logs != "" {
+		t.Errorf("expected client error not to be logged, got %q", logs)
+	}
+
+	w, logs := serveError(router, MethodGet, "/written", "", "")
+	if w.Code != http.StatusOK || w.Body.String() != "foo" || !strings.Contains(logs, "written") {
+		t.Errorf("unexpected response %d %q, logs %q", w.Code, w.Body.String(), logs)
+	}
+
+	w, _ = serveError(router, MethodGet, "/nil", "", "")
+	if w.Code != http.StatusOK || w.Body.String() != "ok" {
+		t.Errorf("unexpected response %d %q", w.Code, w.Body.String())
+	}
+}
+
+func TestDefaultErrorHandlerFormats(t *testing.T) {
+	router := newErrorTestRouter()
+
+	w, _ := serveError(router, MethodGet, "/http", MIMEXML, "")
+	if w.Header().Get("Content-Type") != MIMEXML {
+		t.Errorf("expected content type %q, got %q", MIMEXML, w.Header().Get("Content-Type"))
+	}
+	want := `<error status="404" code="user_not_found"><message>no such user</message></error>`
+	if !strings.HasSuffix(w.Body.String(), want) {
+		t.Errorf("expected body %q, got %q", want, w.Body.String())
+	}
+
+	w, _ = serveError(router, MethodGet, "/http", "text/html,*/*;q=0.8", "")
+	if w.Code != http.StatusNotFound || !strings.Contains(w.Body.String(), "<h1>404 no such user</h1>") {
+		t.Errorf("unexpected HTML response %d %q", w.Code, w.Body.String())
+	}
+	if w.Header().Get("Vary") != "Accept" {
+		t.Errorf("expected Vary header %q, got %q", "Accept", w.Header().Get("Vary"))
+	}
+}
+
+func TestDefaultErrorHandlerProblemDetails(t *testing.T) {
+	router := newErrorTestRouter()
+	router.ProblemDetails = true
+
+	w, _ := serveError(router, MethodPost, "/validate", "", "{}")
+	if w.Header().Get("Content-Type") != MIMEProblemJSON {
+		t.Errorf("expected content type %q, got %q", MIMEProblemJSON, w.Header().Get("Content-Type"))
+	}
+	var p map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &p); err != nil {
+		t.Fatal(err)
+	}
+	if p["title"] != "Unprocessable Entity" || p["status"] != float64(422) || p["instance"] != "/validate" || p["errors"] == nil {
+		t.Errorf("unexpected problem %v", p)
+	}
+
+	w, _ = serveError(router, MethodGet, "/http", MIMEXML, "")
+	if w.Header().Get("Content-Type") != MIMEProblemXML {
+		t.Errorf("expected content type %q, got %q", MIMEProblemXML, w.Header().Get("Content-Type"))
+	}
+	var xp problem
+	if err := xml.Unmarshal(w.Body.Bytes(), &xp); err != nil {
+		t.Fatal(err)
+	}
+	if xp.XMLName.Space != "urn:ietf:rfc:7807" || xp.Status != 404 || xp.Code != "user_not_found" || xp.Detail != "no such user" {
+		t.Errorf("unexpected problem %+v", xp)
+	}
+}
+
+func TestRouterErrorHandler(t *testing.T) {
+	router := newErrorTestRouter()
+	var handled error
+	router.ErrorHandler = func(ctx *Context, err error) {
+		handled = err
+		ctx.Response.WriteHeader(http.StatusTeapot)
+	}
+
+	w, _ := serveError(router, MethodGet, "/internal", "", "")
+	if w.Code != http.StatusTeapot || handled == nil || handled.Error() != "database is down" {
+		t.Errorf("unexpected response %d, handled error %v", w.Code, handled)
+	}
+}
+
+func TestHTTPError(t *testing.T) {
+	e := NewHTTPError(http.StatusForbidden, "")
+	if e.Message != "Forbidden" || e.Error() != "403 Forbidden" {
+		t.Errorf("unexpected error %+v", e)
+	}
+
+	cause := errors.New("permission denied")
+	e = &HTTPError{Status: http.StatusForbidden, Message: "forbidden", Err: cause}
+	if e.Error() != "403 forbidden: permission denied" || !errors.Is(e, cause) {
+		t.Errorf("unexpected error %q", e.Error())
+	}
+
+	if toHTTPError(fmt.Errorf("wrapped: %w", e)) != e {
+		t.Error("expected the wrapped HTTPError")
+	}
+	noStatus := &HTTPError{Message: "foo"}
+	if e := toHTTPError(noStatus); e.Status != http.StatusInternalServerError || e.Message != "foo" || noStatus.Status != 0 {
+		t.Errorf("expected 500 for the HTTPError without status, got %+v", e)
+	}
+	if toHTTPError(errEmptyRequestBody).Status != http.StatusBadRequest {
+		t.Error("expected 400 Bad Request")
+	}
+	if toHTTPError(errUnsupportedMediaType).Status != http.StatusUnsupportedMediaType {
+		t.Error("expected 415 Unsupported Media Type")
+	}
+	bindErr := &BindError{Field: "Age", Value: "foo", Err: errors.New("invalid")}
+	if e := toHTTPError(bindErr); e.Status != http.StatusBadRequest || e.Message != bindErr.Error() {
+		t.Errorf("unexpected error %+v", e)
+	}
+}
+
+func TestE(t *testing.T) {
+	want := errors.New("foo")
+	var got error
+	router := NewRouter()
+	router.ErrorHandler = func(ctx *Context, err error) {
+		got = err
+	}
+	for _, err := range []error{want, nil} {
+		got = nil
+		handle := E(func(ctx *Context) error {
+			return err
+		})
+		req := httptest.NewRequest(MethodGet, "/", nil)
+		ctx := newContext(nil, httptest.NewRecorder(), req)
+		ctx.router = router
+		handle(ctx)
+		if got != err {
+			t.Errorf("expected error %v, got %v", err, got)
+		}
+	}
+}
@@ -0,0 +1,242 @@
+// Copyright 2016 The Gem Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file.
+
+package gem
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// CSRFTokenKey is the key of user value that stores the CSRF token,
+// it is used by the "csrfToken" function of templates, and should be
+// consistent with the CSRF middleware.
+var CSRFTokenKey = "csrf_token"
+
+// DefaultDateLayout is the default layout of the "date" function
+// of templates.
+const DefaultDateLayout = "2006-01-02 15:04:05"
+
+var (
+	errNoTemplatesRouter = errors.New("no router associated with the templates")
+	errOddDictArguments  = errors.New("dict requires even number of arguments")
+)
+
+// defaultFuncs returns the default functions of templates,
+// see NewTemplates.
+func (ts *Templates) defaultFuncs() template.FuncMap {
+	return template.FuncMap{
+		"url": func(name string, params ...interface{}) (string, error) {
+			if ts.router == nil {
+				return "", errNoTemplatesRouter
+			}
+			return ts.router.URL(name, params...)
+		},
+		"asset": func(path string) string {
+			return strings.TrimSuffix(ts.AssetPrefix, "/") + "/" + strings.TrimPrefix(path, "/")
+		},
+		"date":      formatDate,
+		"number":    formatNumber,
+		"upper":     strings.ToUpper,
+		"lower":     strings.ToLower,
+		"title":     title,
+		"trim":      strings.TrimSpace,
+		"truncate":  truncate,
+		"replace":   strings.ReplaceAll,
+		"contains":  strings.Contains,
+		"hasPrefix": strings.HasPrefix,
+		"hasSuffix": strings.HasSuffix,
+		"split":     strings.Split,
+		"join":      strings.Join,
+		"json": func(v interface{}) (template.JS, error) {
+			data, err := json.Marshal(v)
+			return template.JS(data), err
+		},
+		"safeHTML": func(s string) template.HTML {
+			return template.HTML(s)
+		},
+		"safeURL": func(s string) template.URL {
+			return template.URL(s)
+		},
+		"safeJS": func(s string) template.JS {
+			return template.JS(s)
+		},
+		"dict":      dict,
+		"list":      list,
+		"csrfToken": csrfToken,
+	}
+}
+
+func formatDate(v interface{}, layout ...string) (string, error) {
+	var t time.Time
+	switch value := v.(type) {
+	case time.Time:
+		t = value
+	case *time.Time:
+		if value == nil {
+			return "", nil
+		}
+		t = *value
+	default:
+		return "", fmt.Errorf("unable to format %T as date", v)
+	}
+
+	if t.IsZero() {
+		return "", nil
+	}
+	if len(layout) > 0 {
+		return t.Format(layout[0]), nil
+	}
+
+	return t.Format(DefaultDateLayout), nil
+}
+
+// formatNumber formats the number with thousands separators, the
+// decimals defaults to 0 for integers, and 2 for floats.
+func formatNumber(v interface{}, decimals ...int) (string, error) {
+	var s string
+	switch n := v.(type) {
+	case int:
+		s = strconv.FormatInt(int64(n), 10)
+	case int8:
+		s = strconv.FormatInt(int64(n), 10)
+	case int16:
+		s = strconv.FormatInt(int64(n), 10)
+	case int32:
+		s = strconv.FormatInt(int64(n), 10)
+	case int64:
+		s = strconv.FormatInt(n, 10)
+	case uint:
+		s = strconv.FormatUint(uint64(n), 10)
+	case uint8:
+		s = strconv.FormatUint(uint64(n), 10)
+	case uint16:
+		s = strconv.FormatUint(uint64(n), 10)
+	case uint32:
+		s = strconv.FormatUint(uint64(n), 10)
+	case uint64:
+		s = strconv.FormatUint(n, 10)
+	case float32, float64:
+		prec := 2
+		if len(decimals) > 0 {
+			prec = decimals[0]
+		}
+		f, _ := n.(float64)
+		if f32, ok := n.(float32); ok {
+			f = float64(f32)
+		}
+		s = strconv.FormatFloat(f, 'f', prec, 64)
+	default:
+		return "", fmt.Errorf("unable to format %T as number", v)
+	}
+
+	intPart, fracPart := s, ""
+	if idx := strings.IndexByte(s, '.'); idx >= 0 {
+		intPart, fracPart = s[:idx], s[idx+1:]
+	} else if len(decimals) > 0 && decimals[0] > 0 {
+		fracPart = strings.Repeat("0", decimals[0])
+	}
+
+	sign := ""
+	if intPart[0] == '-' {
+		sign, intPart = "-", intPart[1:]
+	}
+
+	var b strings.Builder
+	b.WriteString(sign)
+	for i := 0; i < len(intPart); i++ {
+		if i > 0 && (len(intPart)-i)%3 == 0 {
+			b.WriteByte(',')
+		}
+		b.WriteByte(intPart[i])
+	}
+	if fracPart != "" {
+		b.WriteByte('.')
+		b.WriteString(fracPart)
+	}
+
+	return b.String(), nil
+}
+
+// title converts the first letter of each word to upper case.
+func title(s string) string {
+	prev := ' '
+	return strings.Map(func(r rune) rune {
+		if unicode.IsSpace(prev) {
+			prev = r
+			return unicode.ToUpper(r)
+		}
+		prev = r
+		return r
+	}, s)
+}
+
+// truncate truncates the string to n characters, the "..." is
+// appended if truncated.
+func truncate(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+
+	runes := []rune(s)
+	return string(runes[:n]) + "..."
+}
+
+func dict(pairs ...interface{}) (map[string]interface{}, error) {
+	if len(pairs)%2 != 0 {
+		return nil, errOddDictArguments
+	}
+
+	m := make(map[string]interface{}, len(pairs)/2)
+	for i := 0; i < len(pairs); i += 2 {
+		key, ok := pairs[i].(string)
+		if !ok {
+			return nil, fmt.Errorf("dict keys must be strings, got %T", pairs[i])
+		}
+		m[key] = pairs[i+1]
+	}
+
+	return m, nil
+}
+
+func list(items ...interface{}) []interface{} {
+	return items
+}
+
+// csrfToken returns the CSRF token of the given context, an empty
+// string is returned if no context is given, Context.Render binds
+// the rendering context, see bindContextFuncs.
+func csrfToken(ctx ...*Context) string {
+	if len(ctx) == 0 || ctx[0] == nil {
+		return ""
+	}
+
+	token, _ := ctx[0].UserValue(CSRFTokenKey).(string)
+	return token
+}
+
+// bindContextFuncs binds the functions that depend on the context to
+// ctx, unless they were overridden by FuncMap. The tmpl must be owned
+// by the caller, see Templates.Render.
+func (ts *Templates) bindContextFuncs(tmpl *template.Template, ctx *Context) {
+	if _, ok := ts.FuncMap["csrfToken"]; ok {
+		return
+	}
+
+	tmpl.Funcs(template.FuncMap{
+		"csrfToken": func(c ...*Context) string {
+			if len(c) == 0 {
+				return csrfToken(ctx)
+			}
+			return csrfToken(c...)
+		},
+	})
+}
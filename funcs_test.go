@@ -0,0 +1,91 @@
+// Copyright 2016 The Gem Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file.
+
+package gem
+
+import (
+	"bytes"
+	"html/template"
+	"testing"
+	"time"
+)
+
+func executeFuncs(ts *Templates, text string, data interface{}) (string, error) {
+	tmpl, err := template.New("test").Funcs(ts.defaultFuncs()).Funcs(ts.FuncMap).Parse(text)
+	if err != nil {
+		return "", err
+	}
+	buf := &bytes.Buffer{}
+	err = tmpl.Execute(buf, data)
+	return buf.String(), err
+}
+
+func TestDefaultFuncs(t *testing.T) {
+	ts := NewTemplates("")
+	ts.AssetPrefix = "https://cdn.example.com/"
+	router := NewRouter()
+	router.GET("/users/:name", func(ctx *Context) {}, NewNamedHandlerOption("user"))
+	router.SetTemplates(ts)
+
+	ctx := &Context{}
+	ctx.SetUserValue(CSRFTokenKey, "token")
+	created := time.Date(2016, 12, 25, 8, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		text string
+		data interface{}
+		want string
+	}{
+		{`{{url "user" "foo"}}`, nil, "/users/foo"},
+		{`{{asset "/css/main.css"}}`, nil, "https://cdn.example.com/css/main.css"},
+		{`{{date .}}`, created, "2016-12-25 08:30:00"},
+		{`{{date . "Jan 2, 2006"}}`, &created, "Dec 25, 2016"},
+		{`{{date .}}`, time.Time{}, ""},
+		{`{{number 1234567}}`, nil, "1,234,567"},
+		{`{{number -1234567.891}}`, nil, "-1,234,567.89"},
+		{`{{number 1234.5 0}}`, nil, "1,234"},
+		{`{{number 100 2}}`, nil, "100.00"},
+		{`{{upper "foo"}} {{lower "FOO"}} {{title "hello  world"}}`, nil, "FOO foo Hello  World"},
+		{`{{trim " foo "}}|{{truncate "hello world" 5}}|{{truncate "foo" 5}}`, nil, "foo|hello...|foo"},
+		{`{{replace "foo" "o" "0"}} {{contains "foo" "o"}} {{hasPrefix "foo" "f"}} {{hasSuffix "foo" "f"}}`, nil, "f00 true true false"},
+		{`{{join (split "a,b" ",") "|"}}`, nil, "a|b"},
+		{`<script>var user = {{json .}};</script>`, map[string]string{"name": "foo"}, `<script>var user = {"name":"foo"};</script>`},
+		{`{{safeHTML "<b>foo</b>"}}`, nil, "<b>foo</b>"},
+		{`<a href="{{safeURL "javascript:alert()"}}"></a>`, nil, `<a href="javascript:alert%28%29"></a>`},
+		{`{{with dict "name" "foo" "age" 18}}{{.name}} {{.age}}{{end}}`, nil, "foo 18"},
+		{`{{range list 1 2 3}}{{.}}{{end}}`, nil, "123"},
+		{`{{csrfToken .}}`, ctx, "token"},
+		{`{{csrfToken .}}`, (*Context)(nil), ""},
+	}
+	for _, test := range tests {
+		got, err := executeFuncs(ts, test.text, test.data)
+		if err != nil {
+			t.Errorf("failed to execute %s: %s", test.text, err)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("expected %q for %s, got %q", test.want, test.text, got)
+		}
+	}
+}
+
+func TestDefaultFuncsError(t *testing.T) {
+	ts := NewTemplates("")
+	tests := []struct {
+		text string
+		data interface{}
+	}{
+		{`{{url "user"}}`, nil},
+		{`{{date .}}`, "foo"},
+		{`{{number .}}`, "foo"},
+		{`{{dict "name"}}`, nil},
+		{`{{dict 1 2}}`, nil},
+		{`{{json .}}`, make(chan int)},
+	}
+	for _, test := range tests {
+		if _, err := executeFuncs(ts, test.text, test.data); err == nil {
+			t.Errorf("expected non-nil error for %s, got nil", test.text)
+		}
+	}
+}
@@ -0,0 +1,149 @@
+// Copyright 2016 The Gem Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file.
+
+package gem
+
+import (
+	"io/fs"
+	"net/http"
+	"strings"
+)
+
+// Group is a set of routes which share the same path prefix
+// and middlewares.
+//
+// The middlewares of group would be invoked before the
+// middlewares of the specific handler option.
+type Group struct {
+	router      *Router
+	prefix      string
+	middlewares []Middleware
+}
+
+// Group returns a Group instance by the given prefix and
+// handler option.
+//
+// The prefix must begin with '/', a trailing slash of
+// the prefix would be ignored.
+func (r *Router) Group(prefix string, opts ...*HandlerOption) *Group {
+	return newGroup(r, prefix, nil, opts)
+}
+
+func newGroup(router *Router, prefix string, middlewares []Middleware, opts []*HandlerOption) *Group {
+	if prefix == "" || prefix[0] != '/' {
+		panic("prefix must begin with '/' in prefix '" + prefix + "'")
+	}
+
+	g := &Group{
+		router: router,
+		prefix: strings.TrimSuffix(prefix, "/"),
+	}
+	g.middlewares = append(g.middlewares, middlewares...)
+	if len(opts) > 0 && opts[0] != nil {
+		g.middlewares = append(g.middlewares, opts[0].Middlewares...)
+	}
+
+	return g
+}
+
+// Group returns a nested group, the prefix and middlewares
+// of the current group would be inherited.
+func (g *Group) Group(prefix string, opts ...*HandlerOption) *Group {
+	return newGroup(g.router, g.prefix+prefix, g.middlewares, opts)
+}
+
+// Prefix returns the path prefix of group.
+func (g *Group) Prefix() string {
+	return g.prefix
+}
+
+// Use register middleware for all handlers of group.
+//
+// It only takes effect on the handlers that registered
+// after calling Use.
+func (g *Group) Use(middleware Middleware) {
+	g.middlewares = append(g.middlewares, middleware)
+}
+
+// GET is a shortcut for group.Handle("GET", path, handle)
+func (g *Group) GET(path string, handle HandlerFunc, opts ...*HandlerOption) {
+	g.Handle(MethodGet, path, handle, opts...)
+}
+
+// HEAD is a shortcut for group.Handle("HEAD", path, handle)
+func (g *Group) HEAD(path string, handle HandlerFunc, opts ...*HandlerOption) {
+	g.Handle(MethodHead, path, handle, opts...)
+}
+
+// OPTIONS is a shortcut for group.Handle("OPTIONS", path, handle)
+func (g *Group) OPTIONS(path string, handle HandlerFunc, opts ...*HandlerOption) {
+	g.Handle(MethodOptions, path, handle, opts...)
+}
+
+// POST is a shortcut for group.Handle("POST", path, handle)
+func (g *Group) POST(path string, handle HandlerFunc, opts ...*HandlerOption) {
+	g.Handle(MethodPost, path, handle, opts...)
+}
+
+// PUT is a shortcut for group.Handle("PUT", path, handle)
+func (g *Group) PUT(path string, handle HandlerFunc, opts ...*HandlerOption) {
+	g.Handle(MethodPut, path, handle, opts...)
+}
+
+// PATCH is a shortcut for group.Handle("PATCH", path, handle)
+func (g *Group) PATCH(path string, handle HandlerFunc, opts ...*HandlerOption) {
+	g.Handle(MethodPatch, path, handle, opts...)
+}
+
+// DELETE is a shortcut for group.Handle("DELETE", path, handle)
+func (g *Group) DELETE(path string, handle HandlerFunc, opts ...*HandlerOption) {
+	g.Handle(MethodDelete, path, handle, opts...)
+}
+
+// Handle registers a new request handle with the given path and method,
+// the path would be prefixed with the group's prefix.
+//
+// See Router.Handle.
+func (g *Group) Handle(method, path string, handle HandlerFunc, opts ...*HandlerOption) {
+	g.router.Handle(method, g.path(path), handle, g.handlerOption(opts))
+}
+
+// ServeFiles serves files from the given file system root,
+// the path would be prefixed with the group's prefix.
+//
+// See Router.ServeFiles.
+func (g *Group) ServeFiles(path string, root http.FileSystem, opts ...*HandlerOption) {
+	g.router.ServeFiles(g.path(path), root, g.handlerOption(opts))
+}
+
+// ServeFS serves files from the given fs.FS, the path would be
+// prefixed with the group's prefix.
+//
+// See Router.ServeFS.
+func (g *Group) ServeFS(path string, fsys fs.FS, opts ...*HandlerOption) {
+	g.router.ServeFS(g.path(path), fsys, g.handlerOption(opts))
+}
+
+func (g *Group) path(path string) string {
+	if path == "" || path[0] != '/' {
+		panic("path must begin with '/' in path '" + path + "'")
+	}
+
+	return g.prefix + path
+}
+
+// handlerOption returns a handler option that the group's middlewares
+// were prepended to the middlewares of the given option.
+func (g *Group) handlerOption(opts []*HandlerOption) *HandlerOption {
+	option := &HandlerOption{}
+	if len(opts) > 0 && opts[0] != nil {
+		*option = *opts[0]
+	}
+
+	middlewares := make([]Middleware, 0, len(g.middlewares)+len(option.Middlewares))
+	middlewares = append(middlewares, g.middlewares...)
+	option.Middlewares = append(middlewares, option.Middlewares...)
+
+	return option
+}
@@ -0,0 +1,178 @@
+// Copyright 2016 The Gem Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file.
+
+package gem
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"testing/fstest"
+)
+
+type orderMiddleware struct {
+	name  string
+	order *[]string
+}
+
+func (m *orderMiddleware) Wrap(next Handler) Handler {
+	return HandlerFunc(func(ctx *Context) {
+		*m.order = append(*m.order, m.name)
+		next.Handle(ctx)
+	})
+}
+
+func TestRouter_Group(t *testing.T) {
+	var order []string
+	m1 := &orderMiddleware{"m1", &order}
+	m2 := &orderMiddleware{"m2", &order}
+	m3 := &orderMiddleware{"m3", &order}
+
+	router := NewRouter()
+	api := router.Group("/api/", NewHandlerOption(m1))
+	if api.Prefix() != "/api" {
+		t.Errorf("expected prefix %q, got %q", "/api", api.Prefix())
+	}
+
+	v1 := api.Group("/v1", NewHandlerOption(m2))
+	if v1.Prefix() != "/api/v1" {
+		t.Errorf("expected prefix %q, got %q", "/api/v1", v1.Prefix())
+	}
+
+	routed := false
+	v1.GET("/users/:name", func(ctx *Context) {
+		routed = true
+		if ctx.UserValue("name") != "foo" {
+			t.Errorf("expected user value %q, got %v", "foo", ctx.UserValue("name"))
+		}
+	}, NewHandlerOption(m3))
+
+	w := new(mockResponseWriter)
+	req, _ := http.NewRequest(MethodGet, "/api/v1/users/foo", nil)
+	router.Handler().Handle(newContext(nil, w, req))
+	if !routed {
+		t.Fatal("routing group failed")
+	}
+	if want := []string{"m1", "m2", "m3"}; !reflect.DeepEqual(order, want) {
+		t.Errorf("expected middlewares order %v, got %v", want, order)
+	}
+
+	// the parent group should not be affected by the nested group.
+	order = nil
+	api.GET("/ping", func(ctx *Context) {})
+	req, _ = http.NewRequest(MethodGet, "/api/ping", nil)
+	router.Handler().Handle(newContext(nil, w, req))
+	if want := []string{"m1"}; !reflect.DeepEqual(order, want) {
+		t.Errorf("expected middlewares order %v, got %v", want, order)
+	}
+}
+
+func TestGroup_Use(t *testing.T) {
+	m := &testMiddleware{}
+
+	router := NewRouter()
+	g := router.Group("/")
+	g.Use(m)
+	g.POST("/", func(ctx *Context) {})
+
+	w := new(mockResponseWriter)
+	req, _ := http.NewRequest(MethodPost, "/", nil)
+	router.Handler().Handle(newContext(nil, w, req))
+	if !m.handled {
+		t.Error("use group middleware failed")
+	}
+}
+
+func TestGroupAPI(t *testing.T) {
+	var get, head, options, post, put, patch, delete bool
+
+	router := NewRouter()
+	g := router.Group("/g")
+	g.GET("/GET", func(ctx *Context) {
+		get = true
+	})
+	g.HEAD("/GET", func(ctx *Context) {
+		head = true
+	})
+	g.OPTIONS("/GET", func(ctx *Context) {
+		options = true
+	})
+	g.POST("/POST", func(ctx *Context) {
+		post = true
+	})
+	g.PUT("/PUT", func(ctx *Context) {
+		put = true
+	})
+	g.PATCH("/PATCH", func(ctx *Context) {
+		patch = true
+	})
+	g.DELETE("/DELETE", func(ctx *Context) {
+		delete = true
+	})
+
+	requests := []struct {
+		method string
+		path   string
+		routed *bool
+	}{
+		{MethodGet, "/g/GET", &get},
+		{MethodHead, "/g/GET", &head},
+		{MethodOptions, "/g/GET", &options},
+		{MethodPost, "/g/POST", &post},
+		{MethodPut, "/g/PUT", &put},
+		{MethodPatch, "/g/PATCH", &patch},
+		{MethodDelete, "/g/DELETE", &delete},
+	}
+	for _, request := range requests {
+		w := new(mockResponseWriter)
+		r, _ := http.NewRequest(request.method, request.path, nil)
+		router.Handler().Handle(newContext(nil, w, r))
+		if !*request.routed {
+			t.Errorf("routing %s %s failed", request.method, request.path)
+		}
+	}
+}
+
+func TestGroup_ServeFiles(t *testing.T) {
+	router := NewRouter()
+	mfs := &mockFileSystem{}
+
+	router.Group("/static").ServeFiles("/*filepath", mfs)
+	w := new(mockResponseWriter)
+	r, _ := http.NewRequest(MethodGet, "/static/favicon.ico", nil)
+	router.handle(newContext(nil, w, r))
+	if !mfs.opened {
+		t.Error("serving file failed")
+	}
+}
+
+func TestGroup_ServeFS(t *testing.T) {
+	router := NewRouter()
+	fsys := fstest.MapFS{"favicon.ico": {Data: []byte("icon")}}
+
+	router.Group("/static").ServeFS("/*filepath", fsys)
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest(MethodGet, "/static/favicon.ico", nil)
+	router.handle(newContext(nil, w, r))
+	if w.Body.String() != "icon" {
+		t.Errorf("expected body %q, got %q", "icon", w.Body.String())
+	}
+}
+
+func TestGroupInvalidPath(t *testing.T) {
+	router := NewRouter()
+
+	if recv := catchPanic(func() {
+		router.Group("api")
+	}); recv == nil {
+		t.Error("creating group with prefix not beginning with '/' did not panic")
+	}
+
+	if recv := catchPanic(func() {
+		router.Group("/api").GET("users", func(ctx *Context) {})
+	}); recv == nil {
+		t.Error("registering path not beginning with '/' did not panic")
+	}
+}
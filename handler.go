@@ -22,6 +22,10 @@ func (f HandlerFunc) Handle(ctx *Context) {
 
 // HandlerOption option for handler.
 type HandlerOption struct {
+	// Name is the name of route, it is used to generate URL
+	// via Router.URL, empty name means the route is anonymous.
+	Name string
+
 	Middlewares []Middleware
 }
 
@@ -32,3 +36,12 @@ func NewHandlerOption(middlewares ...Middleware) *HandlerOption {
 		Middlewares: middlewares,
 	}
 }
+
+// NewNamedHandlerOption returns HandlerOption instance by the
+// given route's name and middlewares.
+func NewNamedHandlerOption(name string, middlewares ...Middleware) *HandlerOption {
+	return &HandlerOption{
+		Name:        name,
+		Middlewares: middlewares,
+	}
+}
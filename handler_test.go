@@ -14,3 +14,15 @@ func TestNewHandlerOption(t *testing.T) {
 		t.Errorf("expected option middleware count %d, got %d", 3, len(option.Middlewares))
 	}
 }
+
+func TestNewNamedHandlerOption(t *testing.T) {
+	var m1, m2 Middleware
+	option := NewNamedHandlerOption("user", m1, m2)
+
+	if option.Name != "user" {
+		t.Errorf("expected option name %q, got %q", "user", option.Name)
+	}
+	if len(option.Middlewares) != 2 {
+		t.Errorf("expected option middleware count %d, got %d", 2, len(option.Middlewares))
+	}
+}
@@ -5,13 +5,57 @@
 package gem
 
 import (
+	"fmt"
+	"io"
 	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
 
 	"github.com/go-gem/log"
 )
 
 var defaultLogger = log.New(os.Stdout, log.LstdFlags, log.LevelAll)
 
+var logLevels = map[string]int{
+	"debug": log.LevelDebug,
+	"info":  log.LevelInfo,
+	"error": log.LevelError,
+	"fatal": log.LevelFatal,
+}
+
+// newLogger returns a logger configured by the given option, the
+// closer is non-nil if the output is a file.
+func newLogger(opt LoggerOption) (Logger, io.Closer, error) {
+	level := log.LevelAll
+	if len(opt.Levels) > 0 {
+		level = 0
+		for _, name := range opt.Levels {
+			l, ok := logLevels[name]
+			if !ok {
+				return nil, nil, fmt.Errorf("unknown log level %q", name)
+			}
+			level |= l
+		}
+	}
+
+	switch opt.Output {
+	case "", "stdout":
+		return log.New(os.Stdout, log.LstdFlags, level), nil, nil
+	case "stderr":
+		return log.New(os.Stderr, log.LstdFlags, level), nil, nil
+	}
+
+	file, err := os.OpenFile(opt.Output, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return log.New(file, log.LstdFlags, level), file, nil
+}
+
 // Logger defines a logging interface.
 type Logger interface {
 	Debug(v ...interface{})
@@ -26,3 +70,179 @@ type Logger interface {
 	Fatal(v ...interface{})
 	Fatalf(format string, v ...interface{})
 }
+
+// Fields is a set of structured logging fields.
+type Fields map[string]interface{}
+
+// FieldLogger is a Logger that supports structured fields, the
+// fields are attached to every entry of the returned logger.
+type FieldLogger interface {
+	Logger
+
+	WithField(key string, value interface{}) FieldLogger
+	WithFields(fields Fields) FieldLogger
+}
+
+var (
+	loggerType = reflect.TypeOf((*Logger)(nil)).Elem()
+	fieldsType = reflect.TypeOf(Fields(nil))
+)
+
+// NewFieldLogger returns a FieldLogger that wraps the given logger.
+//
+// If the logger implements FieldLogger, it is returned directly. The
+// logrus-style loggers, whose WithField and WithFields methods return
+// a Logger, such as *logrus.Logger and *logrus.Entry, are adapted via
+// reflection, the methods are resolved once per type. Otherwise, the
+// fields are appended to the messages in "key=value" form.
+func NewFieldLogger(logger Logger) FieldLogger {
+	if l, ok := logger.(FieldLogger); ok {
+		return l
+	}
+
+	if methods := lookupFieldMethods(reflect.TypeOf(logger)); methods != nil {
+		return &reflectFieldLogger{Logger: logger, receiver: reflect.ValueOf(logger), methods: methods}
+	}
+
+	return &fieldLogger{logger: logger}
+}
+
+// fieldMethods are the WithField and WithFields methods of a
+// logrus-style logger type.
+type fieldMethods struct {
+	withField  reflect.Value
+	withFields reflect.Value
+}
+
+// fieldMethodsCache maps the logger types to *fieldMethods, nil
+// means the type is not a logrus-style logger.
+var fieldMethodsCache sync.Map
+
+func lookupFieldMethods(t reflect.Type) *fieldMethods {
+	if methods, ok := fieldMethodsCache.Load(t); ok {
+		return methods.(*fieldMethods)
+	}
+
+	var methods *fieldMethods
+	withField, ok1 := t.MethodByName("WithField")
+	withFields, ok2 := t.MethodByName("WithFields")
+	if ok1 && ok2 && isWithField(withField.Type) && isWithFields(withFields.Type) {
+		methods = &fieldMethods{withField: withField.Func, withFields: withFields.Func}
+	}
+
+	fieldMethodsCache.Store(t, methods)
+	return methods
+}
+
+// isWithField reports whether t is the type of WithField method,
+// the first argument is the receiver.
+func isWithField(t reflect.Type) bool {
+	return t.NumIn() == 3 && t.In(1).Kind() == reflect.String && t.In(2).Kind() == reflect.Interface &&
+		t.NumOut() == 1 && t.Out(0).Implements(loggerType)
+}
+
+// isWithFields reports whether t is the type of WithFields method,
+// the first argument is the receiver.
+func isWithFields(t reflect.Type) bool {
+	return t.NumIn() == 2 && fieldsType.ConvertibleTo(t.In(1)) &&
+		t.NumOut() == 1 && t.Out(0).Implements(loggerType)
+}
+
+// reflectFieldLogger adapts the logrus-style loggers.
+type reflectFieldLogger struct {
+	Logger
+	receiver reflect.Value
+	methods  *fieldMethods
+}
+
+func (l *reflectFieldLogger) WithField(key string, value interface{}) FieldLogger {
+	out := l.methods.withField.Call([]reflect.Value{l.receiver, reflect.ValueOf(key), reflect.ValueOf(&value).Elem()})
+	return NewFieldLogger(out[0].Interface().(Logger))
+}
+
+func (l *reflectFieldLogger) WithFields(fields Fields) FieldLogger {
+	arg := reflect.ValueOf(fields).Convert(l.methods.withFields.Type().In(1))
+	out := l.methods.withFields.Call([]reflect.Value{l.receiver, arg})
+	return NewFieldLogger(out[0].Interface().(Logger))
+}
+
+// fieldLogger appends the fields to the messages.
+type fieldLogger struct {
+	logger Logger
+	fields Fields
+	suffix string
+}
+
+func (l *fieldLogger) WithField(key string, value interface{}) FieldLogger {
+	return l.WithFields(Fields{key: value})
+}
+
+func (l *fieldLogger) WithFields(fields Fields) FieldLogger {
+	merged := make(Fields, len(l.fields)+len(fields))
+	for k, v := range l.fields {
+		merged[k] = v
+	}
+	for k, v := range fields {
+		merged[k] = v
+	}
+
+	return &fieldLogger{logger: l.logger, fields: merged, suffix: formatFields(merged)}
+}
+
+// formatFields formats the fields as " key=value ..." sorted by key.
+func formatFields(fields Fields) string {
+	keys := make([]string, 0, len(fields))
+	for k := range fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	for _, k := range keys {
+		value := fmt.Sprint(fields[k])
+		if value == "" || strings.ContainsAny(value, " =\"") {
+			value = strconv.Quote(value)
+		}
+		b.WriteString(" " + k + "=" + value)
+	}
+
+	return b.String()
+}
+
+// message appends the fields to msg, the trailing newlines of msg are
+// trimmed.
+func (l *fieldLogger) message(msg string) string {
+	return strings.TrimRight(msg, "\n") + l.suffix
+}
+
+func (l *fieldLogger) Debug(v ...interface{}) {
+	l.logger.Debug(l.message(fmt.Sprint(v...)))
+}
+
+func (l *fieldLogger) Debugf(format string, v ...interface{}) {
+	l.logger.Debug(l.message(fmt.Sprintf(format, v...)))
+}
+
+func (l *fieldLogger) Info(v ...interface{}) {
+	l.logger.Info(l.message(fmt.Sprint(v...)))
+}
+
+func (l *fieldLogger) Infof(format string, v ...interface{}) {
+	l.logger.Info(l.message(fmt.Sprintf(format, v...)))
+}
+
+func (l *fieldLogger) Error(v ...interface{}) {
+	l.logger.Error(l.message(fmt.Sprint(v...)))
+}
+
+func (l *fieldLogger) Errorf(format string, v ...interface{}) {
+	l.logger.Error(l.message(fmt.Sprintf(format, v...)))
+}
+
+func (l *fieldLogger) Fatal(v ...interface{}) {
+	l.logger.Fatal(l.message(fmt.Sprint(v...)))
+}
+
+func (l *fieldLogger) Fatalf(format string, v ...interface{}) {
+	l.logger.Fatal(l.message(fmt.Sprintf(format, v...)))
+}
@@ -0,0 +1,165 @@
+// Copyright 2016 The Gem Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file.
+
+package gem
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+)
+
+type testLogger struct {
+	entries []string
+}
+
+func (l *testLogger) Debug(v ...interface{}) { l.log("debug", fmt.Sprint(v...)) }
+func (l *testLogger) Debugf(format string, v ...interface{}) {
+	l.log("debug", fmt.Sprintf(format, v...))
+}
+func (l *testLogger) Info(v ...interface{})                 { l.log("info", fmt.Sprint(v...)) }
+func (l *testLogger) Infof(format string, v ...interface{}) { l.log("info", fmt.Sprintf(format, v...)) }
+func (l *testLogger) Error(v ...interface{})                { l.log("error", fmt.Sprint(v...)) }
+func (l *testLogger) Errorf(format string, v ...interface{}) {
+	l.log("error", fmt.Sprintf(format, v...))
+}
+func (l *testLogger) Fatal(v ...interface{}) { l.log("fatal", fmt.Sprint(v...)) }
+func (l *testLogger) Fatalf(format string, v ...interface{}) {
+	l.log("fatal", fmt.Sprintf(format, v...))
+}
+
+func (l *testLogger) log(level, msg string) {
+	l.entries = append(l.entries, level+": "+msg)
+}
+
+func TestNewFieldLogger(t *testing.T) {
+	logger := &testLogger{}
+	l := NewFieldLogger(logger).WithFields(Fields{"path": "/users", "empty": ""}).WithField("msg", "hello world")
+	l.Debug("debug")
+	l.Debugf("debug %d\n", 1)
+	l.Info("info")
+	l.Infof("info %d", 1)
+	l.Error("error")
+	l.Errorf("error %d", 1)
+	l.Fatal("fatal")
+	l.Fatalf("fatal %d", 1)
+
+	fields := ` empty="" msg="hello world" path=/users`
+	want := []string{
+		"debug: debug" + fields,
+		"debug: debug 1" + fields,
+		"info: info" + fields,
+		"info: info 1" + fields,
+		"error: error" + fields,
+		"error: error 1" + fields,
+		"fatal: fatal" + fields,
+		"fatal: fatal 1" + fields,
+	}
+	if !reflect.DeepEqual(logger.entries, want) {
+		t.Errorf("expected entries %q, got %q", want, logger.entries)
+	}
+
+	// the parent logger is not affected.
+	logger.entries = nil
+	NewFieldLogger(logger).WithField("foo", "bar")
+	l.Info("info")
+	if logger.entries[0] != "info: info"+fields {
+		t.Errorf("unexpected entry %q", logger.entries[0])
+	}
+
+	if NewFieldLogger(l) != l {
+		t.Error("FieldLogger should be returned directly")
+	}
+}
+
+// testLogrusFields, testLogrusLogger and testLogrusEntry mimic the
+// APIs of logrus.
+type testLogrusFields map[string]interface{}
+
+type testLogrusLogger struct {
+	testLogger
+}
+
+func (l *testLogrusLogger) WithField(key string, value interface{}) *testLogrusEntry {
+	return l.WithFields(testLogrusFields{key: value})
+}
+
+func (l *testLogrusLogger) WithFields(fields testLogrusFields) *testLogrusEntry {
+	return &testLogrusEntry{logger: l, data: fields}
+}
+
+type testLogrusEntry struct {
+	testLogger
+	logger *testLogrusLogger
+	data   testLogrusFields
+}
+
+func (e *testLogrusEntry) WithField(key string, value interface{}) *testLogrusEntry {
+	return e.WithFields(testLogrusFields{key: value})
+}
+
+func (e *testLogrusEntry) WithFields(fields testLogrusFields) *testLogrusEntry {
+	data := testLogrusFields{}
+	for k, v := range e.data {
+		data[k] = v
+	}
+	for k, v := range fields {
+		data[k] = v
+	}
+	return &testLogrusEntry{logger: e.logger, data: data}
+}
+
+func TestNewFieldLoggerLogrus(t *testing.T) {
+	logger := &testLogrusLogger{}
+	l := NewFieldLogger(logger).WithFields(Fields{"path": "/users"}).WithField("nil", nil)
+	if _, ok := l.(*reflectFieldLogger); !ok {
+		t.Fatalf("expected the logrus-style logger to be adapted, got %T", l)
+	}
+
+	entry := l.(*reflectFieldLogger).Logger.(*testLogrusEntry)
+	want := testLogrusFields{"path": "/users", "nil": nil}
+	if !reflect.DeepEqual(entry.data, want) {
+		t.Errorf("expected fields %v, got %v", want, entry.data)
+	}
+}
+
+func TestLookupFieldMethods(t *testing.T) {
+	typ := reflect.TypeOf(&testLogrusLogger{})
+	methods := lookupFieldMethods(typ)
+	if methods == nil || lookupFieldMethods(typ) != methods {
+		t.Errorf("expected the methods to be resolved once, got %v", methods)
+	}
+	if methods := lookupFieldMethods(reflect.TypeOf(&testLogger{})); methods != nil {
+		t.Errorf("expected nil methods for non-logrus logger, got %v", methods)
+	}
+}
+
+func TestNewLogger(t *testing.T) {
+	if _, _, err := newLogger(LoggerOption{Levels: []string{"trace"}}); err == nil {
+		t.Error("expected unknown level error, got nil")
+	}
+
+	for _, output := range []string{"", "stdout", "stderr"} {
+		logger, closer, err := newLogger(LoggerOption{Output: output, Levels: []string{"info", "error"}})
+		if err != nil || logger == nil || closer != nil {
+			t.Errorf("failed to create logger with output %q: %v", output, err)
+		}
+	}
+
+	output := path.Join(os.TempDir(), "logger-"+strconv.Itoa(time.Now().Nanosecond())+".log")
+	defer os.Remove(output)
+	_, closer, err := newLogger(LoggerOption{Output: output})
+	if err != nil || closer == nil {
+		t.Fatalf("failed to create file logger: %v", err)
+	}
+	closer.Close()
+
+	if _, _, err = newLogger(LoggerOption{Output: path.Join(output, "invalid")}); err == nil {
+		t.Error("expected invalid output error, got nil")
+	}
+}
@@ -0,0 +1,252 @@
+// Copyright 2016 The Gem Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file.
+
+package gem
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Renderer renders data into response with the given status code.
+type Renderer interface {
+	Render(ctx *Context, code int, data interface{}) error
+}
+
+// The RendererFunc type is an adapter to allow the use of
+// ordinary functions as Renderer.
+type RendererFunc func(ctx *Context, code int, data interface{}) error
+
+// Render calls f(ctx, code, data).
+func (f RendererFunc) Render(ctx *Context, code int, data interface{}) error {
+	return f(ctx, code, data)
+}
+
+// View is the data of HTML response for Context.Negotiate, the
+// Template is executed with Data, the other renderers render
+// the Data only.
+type View struct {
+	Template *template.Template
+
+	// Name is the name of template to be executed,
+	// the Template itself is executed if empty.
+	Name string
+
+	Data interface{}
+}
+
+var builtinRenderers = map[string]Renderer{
+	MIMEHTML: RendererFunc(renderHTML),
+	MIMEJSON: RendererFunc(func(ctx *Context, code int, data interface{}) error {
+		ctx.JSON(code, data)
+		return nil
+	}),
+	MIMEXML: RendererFunc(func(ctx *Context, code int, data interface{}) error {
+		ctx.XML(code, data)
+		return nil
+	}),
+	MIMEText: RendererFunc(func(ctx *Context, code int, data interface{}) error {
+		ctx.SetContentType(MIMEText + "; charset=utf-8")
+		ctx.Response.WriteHeader(code)
+		_, err := fmt.Fprint(ctx.Response, data)
+		return err
+	}),
+}
+
+func renderHTML(ctx *Context, code int, data interface{}) error {
+	view, ok := data.(*View)
+	if !ok {
+		return fmt.Errorf("unable to render %T as HTML", data)
+	}
+
+	buf := &bytes.Buffer{}
+	var err error
+	if view.Name == "" {
+		err = view.Template.Execute(buf, view.Data)
+	} else {
+		err = view.Template.ExecuteTemplate(buf, view.Name, view.Data)
+	}
+	if err != nil {
+		return err
+	}
+
+	ctx.SetContentType(MIMEHTML)
+	ctx.Response.WriteHeader(code)
+	_, err = buf.WriteTo(ctx.Response)
+	return err
+}
+
+// SetRenderer registers a custom renderer of the given media type for
+// Context.Negotiate, the built-in renderers of "text/html",
+// "application/json", "application/xml" and "text/plain" can be
+// replaced as well.
+func (r *Router) SetRenderer(mediaType string, renderer Renderer) {
+	if r.renderers == nil {
+		r.renderers = make(map[string]Renderer)
+	}
+
+	r.renderers[strings.ToLower(mediaType)] = renderer
+}
+
+func (ctx *Context) renderer(mediaType string) (Renderer, bool) {
+	if ctx.router != nil {
+		if renderer, ok := ctx.router.renderers[mediaType]; ok {
+			return renderer, true
+		}
+	}
+
+	renderer, ok := builtinRenderers[mediaType]
+	return renderer, ok
+}
+
+// offers returns the default offers of Negotiate, the HTML is preferred
+// if data is a *View, the custom media types are appended in order.
+func (ctx *Context) offers(data interface{}) []string {
+	var offers []string
+	if _, ok := data.(*View); ok {
+		offers = append(offers, MIMEHTML)
+	}
+	offers = append(offers, MIMEJSON, MIMEXML, MIMEText)
+
+	if ctx.router != nil {
+		custom := make([]string, 0, len(ctx.router.renderers))
+		for mediaType := range ctx.router.renderers {
+			if _, ok := builtinRenderers[mediaType]; !ok {
+				custom = append(custom, mediaType)
+			}
+		}
+		sort.Strings(custom)
+		offers = append(offers, custom...)
+	}
+
+	return offers
+}
+
+// Negotiate responses data with the media type that is most acceptable
+// by the client according to the Accept header, the offers are the
+// candidate media types in order of preference, all of the registered
+// renderers are offered if absent.
+//
+// The HTML can be rendered only if data is a *View, the View.Data is
+// rendered by the other renderers.
+//
+// If none of the offers is acceptable, the request is answered with
+// 406 Not Acceptable.
+func (ctx *Context) Negotiate(code int, data interface{}, offers ...string) {
+	if len(offers) == 0 {
+		offers = ctx.offers(data)
+	}
+
+	ctx.Response.Header().Add("Vary", "Accept")
+
+	mediaType := NegotiateContentType(ctx.Request.Header.Get("Accept"), offers)
+	renderer, ok := ctx.renderer(strings.ToLower(mediaType))
+	if !ok {
+		http.Error(ctx.Response, http.StatusText(http.StatusNotAcceptable), http.StatusNotAcceptable)
+		return
+	}
+
+	if view, ok := data.(*View); ok && mediaType != MIMEHTML {
+		data = view.Data
+	}
+
+	if err := renderer.Render(ctx, code, data); err != nil {
+		ctx.Logger().Errorf("render error: %s\n", err)
+		if !ctx.Written() {
+			ctx.Response.WriteHeader(http.StatusInternalServerError)
+		}
+	}
+}
+
+// acceptRange is a media range of Accept header.
+type acceptRange struct {
+	typ, subtype string
+	q            float64
+}
+
+func parseAccept(header string) []acceptRange {
+	var ranges []acceptRange
+	for _, part := range strings.Split(header, ",") {
+		params := strings.Split(part, ";")
+		mediaType := strings.ToLower(strings.TrimSpace(params[0]))
+		if mediaType == "" {
+			continue
+		}
+
+		r := acceptRange{q: 1}
+		if idx := strings.IndexByte(mediaType, '/'); idx >= 0 {
+			r.typ, r.subtype = mediaType[:idx], mediaType[idx+1:]
+		} else {
+			// "*" is treated as "*/*".
+			r.typ, r.subtype = mediaType, "*"
+		}
+
+		for _, param := range params[1:] {
+			param = strings.TrimSpace(param)
+			if len(param) > 2 && (param[0] == 'q' || param[0] == 'Q') && param[1] == '=' {
+				if q, err := strconv.ParseFloat(param[2:], 64); err == nil && q >= 0 && q <= 1 {
+					r.q = q
+				}
+			}
+		}
+
+		ranges = append(ranges, r)
+	}
+
+	return ranges
+}
+
+// NegotiateContentType returns the offer that is most acceptable by the
+// given Accept header, the earlier offer is preferred if several offers
+// have the same quality. The first offer would be returned if the header
+// is empty, and an empty string would be returned if none of offers is
+// acceptable.
+func NegotiateContentType(accept string, offers []string) string {
+	if len(offers) == 0 {
+		return ""
+	}
+
+	ranges := parseAccept(accept)
+	if len(ranges) == 0 {
+		return offers[0]
+	}
+
+	best, bestQ := "", 0.0
+	for _, offer := range offers {
+		typ, subtype := strings.ToLower(offer), ""
+		if idx := strings.IndexByte(typ, '/'); idx >= 0 {
+			typ, subtype = typ[:idx], typ[idx+1:]
+		}
+
+		// the most specific range takes precedence.
+		q, specificity := 0.0, -1
+		for _, r := range ranges {
+			var s int
+			switch {
+			case r.typ == typ && r.subtype == subtype:
+				s = 2
+			case r.typ == typ && r.subtype == "*":
+				s = 1
+			case r.typ == "*" && r.subtype == "*":
+				s = 0
+			default:
+				continue
+			}
+			if s > specificity {
+				q, specificity = r.q, s
+			}
+		}
+
+		if q > bestQ {
+			best, bestQ = offer, q
+		}
+	}
+
+	return best
+}
@@ -0,0 +1,155 @@
+// Copyright 2016 The Gem Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file.
+
+package gem
+
+import (
+	"errors"
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestNegotiateContentType(t *testing.T) {
+	offers := []string{MIMEJSON, MIMEXML, MIMEText}
+	tests := []struct {
+		accept string
+		offers []string
+		want   string
+	}{
+		{"", offers, MIMEJSON},
+		{"*/*", offers, MIMEJSON},
+		{"application/xml", offers, MIMEXML},
+		{"text/*", offers, MIMEText},
+		{"application/json;q=0.5, application/xml", offers, MIMEXML},
+		{"application/xml;q=0.9, application/json;q=0.9", offers, MIMEJSON},
+		{"text/*;q=0.2, */*;q=0.1", offers, MIMEText},
+		{"application/*;q=0, */*", offers, MIMEText},
+		{"text/html, application/xhtml+xml, application/xml;q=0.9, */*;q=0.8", offers, MIMEXML},
+		{"Application/XML", offers, MIMEXML},
+		{"image/png", offers, ""},
+		{"application/json;q=0", offers, ""},
+		{"application/json", nil, ""},
+	}
+	for _, test := range tests {
+		if got := NegotiateContentType(test.accept, test.offers); got != test.want {
+			t.Errorf("expected %q for Accept %q, got %q", test.want, test.accept, got)
+		}
+	}
+}
+
+func newNegotiateContext(accept string) (*Context, *httptest.ResponseRecorder) {
+	req, _ := http.NewRequest(MethodGet, "/", nil)
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+	resp := httptest.NewRecorder()
+	return &Context{Request: req, Response: resp, server: New("")}, resp
+}
+
+func TestContext_Negotiate(t *testing.T) {
+	type user struct {
+		Name string `json:"name" xml:"name"`
+	}
+	data := user{Name: "foo"}
+	view := &View{
+		Template: template.Must(template.New("user").Parse(`<p>{{.Name}}</p>`)),
+		Data:     data,
+	}
+
+	tests := []struct {
+		accept      string
+		data        interface{}
+		offers      []string
+		code        int
+		contentType string
+		body        string
+	}{
+		{"", data, nil, 201, MIMEJSON, `{"name":"foo"}`},
+		{"application/xml", data, nil, 201, MIMEXML, `<?xml version="1.0" encoding="UTF-8"?>` + "\n<user><name>foo</name></user>"},
+		{"text/plain", "foo", nil, 201, MIMEText + "; charset=utf-8", "foo"},
+		{"text/html", data, nil, http.StatusNotAcceptable, "text/plain; charset=utf-8", "Not Acceptable\n"},
+		{"text/html, */*", view, nil, 201, MIMEHTML, "<p>foo</p>"},
+		{"application/json", view, nil, 201, MIMEJSON, `{"name":"foo"}`},
+		{"application/json", data, []string{MIMEXML}, http.StatusNotAcceptable, "text/plain; charset=utf-8", "Not Acceptable\n"},
+		{"", data, []string{"image/png"}, http.StatusNotAcceptable, "text/plain; charset=utf-8", "Not Acceptable\n"},
+	}
+	for _, test := range tests {
+		ctx, resp := newNegotiateContext(test.accept)
+		ctx.Negotiate(201, test.data, test.offers...)
+		if resp.Code != test.code {
+			t.Errorf("expected status code %d for Accept %q, got %d", test.code, test.accept, resp.Code)
+		}
+		if got := resp.Header().Get("Content-Type"); got != test.contentType {
+			t.Errorf("expected Content-Type %q for Accept %q, got %q", test.contentType, test.accept, got)
+		}
+		if resp.Body.String() != test.body {
+			t.Errorf("expected body %q for Accept %q, got %q", test.body, test.accept, resp.Body.String())
+		}
+		if resp.Header().Get("Vary") != "Accept" {
+			t.Errorf("expected Vary header %q, got %q", "Accept", resp.Header().Get("Vary"))
+		}
+	}
+}
+
+func TestContext_NegotiateRenderer(t *testing.T) {
+	router := NewRouter()
+	router.SetRenderer("application/x-yaml", RendererFunc(func(ctx *Context, code int, data interface{}) error {
+		ctx.SetContentType("application/x-yaml")
+		ctx.Response.WriteHeader(code)
+		ctx.Response.Write([]byte("name: foo"))
+		return nil
+	}))
+	router.SetRenderer("application/x-error", RendererFunc(func(ctx *Context, code int, data interface{}) error {
+		return errors.New("render error")
+	}))
+
+	ctx, resp := newNegotiateContext("application/x-yaml")
+	ctx.router = router
+	ctx.Negotiate(200, nil)
+	if resp.Body.String() != "name: foo" {
+		t.Errorf("expected body %q, got %q", "name: foo", resp.Body.String())
+	}
+
+	ctx, resp = newNegotiateContext("application/x-error")
+	ctx.router = router
+	ctx.Negotiate(200, nil)
+	if resp.Code != http.StatusInternalServerError {
+		t.Errorf("expected status code %d, got %d", http.StatusInternalServerError, resp.Code)
+	}
+
+	// the built-in renderers can be replaced.
+	router.SetRenderer(MIMEJSON, RendererFunc(func(ctx *Context, code int, data interface{}) error {
+		ctx.Response.WriteHeader(code)
+		ctx.Response.Write([]byte("custom json"))
+		return nil
+	}))
+	ctx, resp = newNegotiateContext("")
+	ctx.router = router
+	ctx.Negotiate(200, nil)
+	if resp.Body.String() != "custom json" {
+		t.Errorf("expected body %q, got %q", "custom json", resp.Body.String())
+	}
+
+	want := []string{MIMEJSON, MIMEXML, MIMEText, "application/x-error", "application/x-yaml"}
+	if got := ctx.offers(nil); !reflect.DeepEqual(got, want) {
+		t.Errorf("expected offers %v, got %v", want, got)
+	}
+}
+
+func TestRenderHTMLError(t *testing.T) {
+	ctx, resp := newNegotiateContext("text/html")
+	ctx.Negotiate(200, &View{
+		Template: template.Must(template.New("user").Parse(`{{.Name}}`)),
+		Name:     "missing",
+	})
+	if resp.Code != http.StatusInternalServerError {
+		t.Errorf("expected status code %d, got %d", http.StatusInternalServerError, resp.Code)
+	}
+	if resp.Body.Len() != 0 {
+		t.Errorf("expected empty body, got %q", resp.Body.String())
+	}
+}
@@ -4,16 +4,70 @@
 
 package gem
 
+import (
+	"encoding/json"
+	"io/fs"
+	"time"
+)
+
 type ServerOption struct {
 	Addr     string `json:"addr"`
 	CertFile string `json:"cert_file"`
 	KeyFile  string `json:"key_file"`
+
+	ReadTimeout       Duration `json:"read_timeout"`
+	ReadHeaderTimeout Duration `json:"read_header_timeout"`
+	WriteTimeout      Duration `json:"write_timeout"`
+	IdleTimeout       Duration `json:"idle_timeout"`
+	ShutdownTimeout   Duration `json:"shutdown_timeout"`
+
+	Logger LoggerOption `json:"logger"`
+}
+
+// LoggerOption configures the logger of server.
+type LoggerOption struct {
+	// Output is one of "stdout", "stderr" or a file path,
+	// defaults to "stdout".
+	Output string `json:"output"`
+
+	// Levels contains the enabled levels: "debug", "info",
+	// "error" and "fatal", all of levels are enabled if empty.
+	Levels []string `json:"levels"`
+}
+
+// Duration is a time.Duration that can be decoded from JSON string,
+// such as "1m30s".
+type Duration time.Duration
+
+// UnmarshalJSON implements the json.Unmarshaler interface.
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+
+	duration, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+
+	*d = Duration(duration)
+	return nil
+}
+
+// MarshalJSON implements the json.Marshaler interface.
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
 }
 
 type AssetsOption struct {
 	Root          string            `json:"root"`
 	Dirs          map[string]string `json:"dirs"`
 	HandlerOption *HandlerOption
+
+	// FS is the file system that the assets are served from,
+	// the OS file system is used if nil.
+	FS fs.FS `json:"-"`
 }
 
 type TemplatesOption struct {
@@ -21,4 +75,24 @@ type TemplatesOption struct {
 	Suffix    string   `json:"suffix"`
 	LayoutDir string   `json:"layout_dir"`
 	Layouts   []string `json:"layouts"`
+
+	// PartialDir is the directory of partials that are
+	// available to every template.
+	PartialDir string `json:"partial_dir"`
+
+	// Extends maps layout's name to its parent layout's name,
+	// such as {"admin": "base"}.
+	Extends map[string]string `json:"extends"`
+
+	// AssetPrefix is the prefix of URL generated by the "asset"
+	// function of templates.
+	AssetPrefix string `json:"asset_prefix"`
+
+	// FS is the file system that the templates are loaded from,
+	// the OS file system is used if nil.
+	FS fs.FS `json:"-"`
+
+	// Development enables the development mode of templates,
+	// otherwise all of the templates are compiled at Init.
+	Development bool `json:"development"`
 }
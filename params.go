@@ -0,0 +1,130 @@
+// Copyright 2016 The Gem Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file.
+
+package gem
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+// PathParam is a single route's parameter, consisting of a key and a value.
+type PathParam struct {
+	Key   string
+	Value string
+}
+
+// PathParams is a PathParam-slice, as returned by the router.
+// The slice is ordered, the first route's parameter is also the
+// first slice value.
+type PathParams []PathParam
+
+// ByName returns the value of the first PathParam which key matches
+// the given name, the second return value reports whether the
+// parameter exists.
+func (ps PathParams) ByName(name string) (string, bool) {
+	for i := 0; i < len(ps); i++ {
+		if ps[i].Key == name {
+			return ps[i].Value, true
+		}
+	}
+
+	return "", false
+}
+
+var errNoParam = errors.New("no such parameter")
+
+// ParamError records a failed conversion of route's parameter.
+type ParamError struct {
+	Name  string // the name of parameter
+	Value string // the raw value of parameter
+	Err   error  // the reason the conversion failed
+}
+
+func (e *ParamError) Error() string {
+	if e.Err == errNoParam {
+		return fmt.Sprintf("no parameter named %q", e.Name)
+	}
+
+	return fmt.Sprintf("invalid value %q of parameter %q: %s", e.Value, e.Name, e.Err)
+}
+
+func (ctx *Context) setParam(key, value string) {
+	ctx.params = append(ctx.params, PathParam{Key: key, Value: value})
+}
+
+// Params returns all of the route's parameters of the current request.
+func (ctx *Context) Params() PathParams {
+	return ctx.params
+}
+
+// Param returns the value of route's parameter by the given name,
+// empty string would be returned if the parameter does not exist.
+func (ctx *Context) Param(name string) string {
+	value, _ := ctx.params.ByName(name)
+	return value
+}
+
+func (ctx *Context) param(name string) (string, error) {
+	if value, ok := ctx.params.ByName(name); ok {
+		return value, nil
+	}
+
+	return "", &ParamError{Name: name, Err: errNoParam}
+}
+
+// ParamInt returns the value of route's parameter as int.
+//
+// If the parameter does not exist or is not a valid integer,
+// a non-nil *ParamError would be returned.
+func (ctx *Context) ParamInt(name string) (int, error) {
+	value, err := ctx.param(name)
+	if err != nil {
+		return 0, err
+	}
+
+	i, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, &ParamError{Name: name, Value: value, Err: err.(*strconv.NumError).Err}
+	}
+
+	return i, nil
+}
+
+// ParamInt64 returns the value of route's parameter as int64.
+//
+// If the parameter does not exist or is not a valid integer,
+// a non-nil *ParamError would be returned.
+func (ctx *Context) ParamInt64(name string) (int64, error) {
+	value, err := ctx.param(name)
+	if err != nil {
+		return 0, err
+	}
+
+	i, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, &ParamError{Name: name, Value: value, Err: err.(*strconv.NumError).Err}
+	}
+
+	return i, nil
+}
+
+// ParamUUID returns the value of route's parameter as UUID.
+//
+// If the parameter does not exist or is not a valid UUID,
+// a non-nil *ParamError would be returned.
+func (ctx *Context) ParamUUID(name string) (UUID, error) {
+	value, err := ctx.param(name)
+	if err != nil {
+		return UUID{}, err
+	}
+
+	uuid, err := ParseUUID(value)
+	if err != nil {
+		return UUID{}, &ParamError{Name: name, Value: value, Err: err}
+	}
+
+	return uuid, nil
+}
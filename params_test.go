@@ -0,0 +1,90 @@
+// Copyright 2016 The Gem Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file.
+
+package gem
+
+import (
+	"net/http"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+func TestPathParams_ByName(t *testing.T) {
+	ps := PathParams{{"name", "foo"}, {"id", "1"}}
+
+	if value, ok := ps.ByName("id"); !ok || value != "1" {
+		t.Errorf("expected %q, got %q", "1", value)
+	}
+	if value, ok := ps.ByName("nonexistent"); ok || value != "" {
+		t.Errorf("expected nonexistent parameter, got %q", value)
+	}
+}
+
+func TestContext_Param(t *testing.T) {
+	router := NewRouter()
+	router.GET("/users/:id/posts/:uuid", func(ctx *Context) {
+		want := PathParams{{"id", "2016"}, {"uuid", "6ba7b810-9dad-11d1-80b4-00c04fd430c8"}}
+		if !reflect.DeepEqual(ctx.Params(), want) {
+			t.Errorf("expected params %v, got %v", want, ctx.Params())
+		}
+
+		if ctx.Param("id") != "2016" {
+			t.Errorf("expected param %q, got %q", "2016", ctx.Param("id"))
+		}
+		if ctx.Param("nonexistent") != "" {
+			t.Errorf("expected empty param, got %q", ctx.Param("nonexistent"))
+		}
+
+		// user values should not shadow the route's parameters.
+		ctx.SetUserValue("id", "shadow")
+		if id, err := ctx.ParamInt("id"); err != nil || id != 2016 {
+			t.Errorf("expected param %d, got %d, %v", 2016, id, err)
+		}
+		if id, err := ctx.ParamInt64("id"); err != nil || id != 2016 {
+			t.Errorf("expected param %d, got %d, %v", 2016, id, err)
+		}
+		if uuid, err := ctx.ParamUUID("uuid"); err != nil || uuid.String() != ctx.Param("uuid") {
+			t.Errorf("expected param %s, got %s, %v", ctx.Param("uuid"), uuid, err)
+		}
+
+		// backward compatibility.
+		if ctx.UserValue("uuid") != ctx.Param("uuid") {
+			t.Errorf("expected user value %q, got %v", ctx.Param("uuid"), ctx.UserValue("uuid"))
+		}
+	})
+
+	w := new(mockResponseWriter)
+	req, _ := http.NewRequest(MethodGet, "/users/2016/posts/6ba7b810-9dad-11d1-80b4-00c04fd430c8", nil)
+	router.Handler().Handle(newContext(nil, w, req))
+}
+
+func TestContext_ParamError(t *testing.T) {
+	ctx := &Context{
+		params: PathParams{{"id", "foo"}, {"big", "99999999999999999999"}},
+	}
+
+	tests := []struct {
+		name string
+		f    func(name string) error
+		msg  string
+	}{
+		{"id", func(name string) error { _, err := ctx.ParamInt(name); return err }, `invalid value "foo" of parameter "id": ` + strconv.ErrSyntax.Error()},
+		{"big", func(name string) error { _, err := ctx.ParamInt64(name); return err }, `invalid value "99999999999999999999" of parameter "big": ` + strconv.ErrRange.Error()},
+		{"id", func(name string) error { _, err := ctx.ParamUUID(name); return err }, `invalid value "foo" of parameter "id": ` + errInvalidUUID.Error()},
+		{"none", func(name string) error { _, err := ctx.ParamInt(name); return err }, `no parameter named "none"`},
+		{"none", func(name string) error { _, err := ctx.ParamInt64(name); return err }, `no parameter named "none"`},
+		{"none", func(name string) error { _, err := ctx.ParamUUID(name); return err }, `no parameter named "none"`},
+	}
+	for _, test := range tests {
+		err := test.f(test.name)
+		if _, ok := err.(*ParamError); !ok {
+			t.Errorf("expected *ParamError, got %T", err)
+			continue
+		}
+		if err.Error() != test.msg {
+			t.Errorf("expected error %q, got %q", test.msg, err)
+		}
+	}
+}
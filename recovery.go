@@ -0,0 +1,109 @@
+// Copyright 2016 The Gem Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file.
+
+package gem
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"net/http"
+	"runtime/debug"
+)
+
+// NewRecovery returns a panic recovery middleware.
+func NewRecovery() *Recovery {
+	return &Recovery{}
+}
+
+// Recovery is a middleware that recovers from panics anywhere in the
+// chain of handlers, it should be registered as the first middleware,
+// such as:
+//
+//	router.Use(gem.NewRecovery())
+//
+// The panic and its stack trace are logged via Context.Logger, and the
+// request is answered with 500 Internal Server Error in JSON or HTML
+// according to the Accept header, unless the header has been written.
+//
+// The http.ErrAbortHandler is not recovered, so that the server is
+// able to abort the response.
+type Recovery struct {
+	// Debug renders the panic and stack trace in response,
+	// it should not be enabled in production.
+	Debug bool
+}
+
+// Wrap implements the Middleware interface.
+func (rc *Recovery) Wrap(next Handler) Handler {
+	return HandlerFunc(func(ctx *Context) {
+		defer func() {
+			rcv := recover()
+			if rcv == nil {
+				return
+			}
+			if rcv == http.ErrAbortHandler {
+				panic(rcv)
+			}
+
+			stack := debug.Stack()
+			ctx.Logger().Errorf("panic recovered: %v\n%s", rcv, stack)
+
+			if ctx.Written() {
+				return
+			}
+			rc.respond(ctx, rcv, stack)
+		}()
+
+		next.Handle(ctx)
+	})
+}
+
+// recoveryError is the JSON response of Recovery.
+type recoveryError struct {
+	Error string `json:"error"`
+	Panic string `json:"panic,omitempty"`
+	Stack string `json:"stack,omitempty"`
+}
+
+func (rc *Recovery) respond(ctx *Context, rcv interface{}, stack []byte) {
+	code := http.StatusInternalServerError
+	data := recoveryError{Error: http.StatusText(code)}
+	if rc.Debug {
+		data.Panic = fmt.Sprint(rcv)
+		data.Stack = string(stack)
+	}
+
+	if NegotiateContentType(ctx.Request.Header.Get("Accept"), []string{MIMEHTML, MIMEJSON}) == MIMEJSON {
+		ctx.JSON(code, data)
+		return
+	}
+
+	buf := &bytes.Buffer{}
+	if err := recoveryTemplate.Execute(buf, data); err != nil {
+		ctx.Logger().Errorf("recovery error: %s\n", err)
+		http.Error(ctx.Response, data.Error, code)
+		return
+	}
+
+	ctx.HTML(code, buf.String())
+}
+
+var recoveryTemplate = template.Must(template.New("recovery").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Error}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+pre { background: #f6f8fa; padding: 1em; overflow: auto; }
+</style>
+</head>
+<body>
+<h1>{{.Error}}</h1>
+{{if .Panic}}<h2>{{.Panic}}</h2>
+<pre>{{.Stack}}</pre>
+{{end}}</body>
+</html>
+`))
@@ -0,0 +1,124 @@
+// Copyright 2016 The Gem Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file.
+
+package gem
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-gem/log"
+)
+
+type panicMiddleware struct{}
+
+func (m *panicMiddleware) Wrap(next Handler) Handler {
+	return HandlerFunc(func(ctx *Context) {
+		if ctx.Request.URL.Path == "/middleware" {
+			panic("middleware panic")
+		}
+		next.Handle(ctx)
+	})
+}
+
+func serveRecovery(rc *Recovery, path, accept string) (*httptest.ResponseRecorder, string) {
+	router := NewRouter()
+	router.Use(rc)
+	router.Use(&panicMiddleware{})
+	router.GET("/handler", func(ctx *Context) {
+		panic("handler panic")
+	})
+	router.GET("/middleware", func(ctx *Context) {})
+	router.GET("/written", func(ctx *Context) {
+		ctx.HTML(http.StatusCreated, "foo")
+		panic("written panic")
+	})
+	router.GET("/abort", func(ctx *Context) {
+		panic(http.ErrAbortHandler)
+	})
+
+	buf := &bytes.Buffer{}
+	srv := New("")
+	srv.SetLogger(log.New(buf, 0, log.LevelAll))
+
+	req := httptest.NewRequest(MethodGet, path, nil)
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+	w := httptest.NewRecorder()
+	router.Handler().Handle(newContext(srv, NewResponseWriter(w), req))
+	return w, buf.String()
+}
+
+func TestRecovery(t *testing.T) {
+	for _, path := range []string{"/handler", "/middleware"} {
+		w, logs := serveRecovery(NewRecovery(), path, "")
+		if w.Code != http.StatusInternalServerError {
+			t.Errorf("%s: expected status code %d, got %d", path, http.StatusInternalServerError, w.Code)
+		}
+		if !strings.HasPrefix(w.Header().Get("Content-Type"), MIMEHTML) {
+			t.Errorf("%s: expected HTML, got %q", path, w.Header().Get("Content-Type"))
+		}
+		if strings.Contains(w.Body.String(), "panic") {
+			t.Errorf("%s: the panic should not be rendered without debug mode: %s", path, w.Body.String())
+		}
+		if !strings.Contains(logs, "panic recovered: "+path[1:]+" panic") || !strings.Contains(logs, "goroutine") {
+			t.Errorf("%s: expected panic and stack trace to be logged, got %q", path, logs)
+		}
+	}
+}
+
+func TestRecoveryJSON(t *testing.T) {
+	rc := NewRecovery()
+	w, _ := serveRecovery(rc, "/handler", MIMEJSON)
+	var data recoveryError
+	if err := json.Unmarshal(w.Body.Bytes(), &data); err != nil {
+		t.Fatal(err)
+	}
+	if w.Code != http.StatusInternalServerError || data.Error != http.StatusText(http.StatusInternalServerError) || data.Panic != "" || data.Stack != "" {
+		t.Errorf("unexpected response %d %+v", w.Code, data)
+	}
+
+	rc.Debug = true
+	w, _ = serveRecovery(rc, "/handler", MIMEJSON)
+	data = recoveryError{}
+	if err := json.Unmarshal(w.Body.Bytes(), &data); err != nil {
+		t.Fatal(err)
+	}
+	if data.Panic != "handler panic" || !strings.Contains(data.Stack, "goroutine") {
+		t.Errorf("unexpected debug response %+v", data)
+	}
+}
+
+func TestRecoveryDebug(t *testing.T) {
+	rc := &Recovery{Debug: true}
+	w, _ := serveRecovery(rc, "/handler", "text/html,*/*;q=0.8")
+	body := w.Body.String()
+	if !strings.Contains(body, "<h2>handler panic</h2>") || !strings.Contains(body, "goroutine") {
+		t.Errorf("expected stack trace page, got %s", body)
+	}
+}
+
+func TestRecoveryWritten(t *testing.T) {
+	w, logs := serveRecovery(NewRecovery(), "/written", "")
+	if w.Code != http.StatusCreated || w.Body.String() != "foo" {
+		t.Errorf("unexpected response %d %q", w.Code, w.Body.String())
+	}
+	if !strings.Contains(logs, "written panic") {
+		t.Errorf("expected panic to be logged, got %q", logs)
+	}
+}
+
+func TestRecoveryAbortHandler(t *testing.T) {
+	recv := catchPanic(func() {
+		serveRecovery(NewRecovery(), "/abort", "")
+	})
+	if recv != http.ErrAbortHandler {
+		t.Errorf("expected panic %v, got %v", http.ErrAbortHandler, recv)
+	}
+}
@@ -0,0 +1,111 @@
+// Copyright 2016 The Gem Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file.
+
+package gem
+
+import "crypto/rand"
+
+// HeaderRequestID is the default header of request ID.
+const HeaderRequestID = "X-Request-Id"
+
+// maxRequestIDLength is the maximum length of incoming request ID.
+const maxRequestIDLength = 128
+
+// NewRequestID returns a request ID middleware
+// with the default header and generator.
+func NewRequestID() *RequestID {
+	return &RequestID{}
+}
+
+// RequestID is a middleware that identifies each request, it honors
+// the incoming request ID, otherwise generates one. The request ID
+// is stored on the Context, see Context.RequestID, and echoed in the
+// response header.
+//
+// The request ID is attached to the entries of Context.Logger, and
+// the AccessLogEntry. It should be registered before the other
+// middlewares, such as:
+//
+//	router.Use(gem.NewRequestID())
+//	router.Use(gem.NewAccessLog(gem.JSONLogFormat))
+type RequestID struct {
+	// Header is the header of request ID, defaults to HeaderRequestID.
+	Header string
+
+	// Generator generates the request ID, defaults to a random
+	// UUID version 4.
+	Generator func() string
+}
+
+// Wrap implements the Middleware interface.
+func (m *RequestID) Wrap(next Handler) Handler {
+	header := m.Header
+	if header == "" {
+		header = HeaderRequestID
+	}
+	generator := m.Generator
+	if generator == nil {
+		generator = newRequestID
+	}
+
+	return HandlerFunc(func(ctx *Context) {
+		id := ctx.Request.Header.Get(header)
+		if !isValidRequestID(id) {
+			id = generator()
+		}
+
+		ctx.SetRequestID(id)
+		ctx.Response.Header().Set(header, id)
+
+		next.Handle(ctx)
+	})
+}
+
+// isValidRequestID reports whether the incoming request ID is safe to
+// be logged, it must consist of visible ASCII characters.
+func isValidRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+
+	for i := 0; i < len(id); i++ {
+		if id[i] < 0x21 || id[i] > 0x7e {
+			return false
+		}
+	}
+
+	return true
+}
+
+// newRequestID returns a random UUID version 4.
+func newRequestID() string {
+	var uuid UUID
+	if _, err := rand.Read(uuid[:]); err != nil {
+		panic(err)
+	}
+	uuid[6] = uuid[6]&0x0f | 0x40
+	uuid[8] = uuid[8]&0x3f | 0x80
+
+	return uuid.String()
+}
+
+// SetRequestID sets the request ID of ctx, see RequestID.
+func (ctx *Context) SetRequestID(id string) {
+	ctx.requestID = id
+	// the request-scoped logger should be rebuilt with the new ID.
+	ctx.logger = nil
+}
+
+// RequestID returns the request ID that was set by the RequestID
+// middleware, or the X-Request-Id header if absent, the header is
+// ignored unless it is a valid request ID.
+func (ctx *Context) RequestID() string {
+	if ctx.requestID == "" && ctx.Request != nil {
+		if id := ctx.Request.Header.Get(HeaderRequestID); isValidRequestID(id) {
+			return id
+		}
+	}
+
+	return ctx.requestID
+}
@@ -0,0 +1,138 @@
+// Copyright 2016 The Gem Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file.
+
+package gem
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/go-gem/log"
+)
+
+var uuidV4Regexp = regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+
+func serveRequestID(m *RequestID, header, id string) (*httptest.ResponseRecorder, string) {
+	var got string
+	handler := m.Wrap(HandlerFunc(func(ctx *Context) {
+		got = ctx.RequestID()
+	}))
+
+	req := httptest.NewRequest(MethodGet, "/", nil)
+	if id != "" {
+		req.Header.Set(header, id)
+	}
+	w := httptest.NewRecorder()
+	handler.Handle(newContext(nil, w, req))
+	return w, got
+}
+
+func TestRequestID(t *testing.T) {
+	m := NewRequestID()
+
+	w, id := serveRequestID(m, HeaderRequestID, "")
+	if !uuidV4Regexp.MatchString(id) {
+		t.Errorf("expected UUID version 4, got %q", id)
+	}
+	if w.Header().Get(HeaderRequestID) != id {
+		t.Errorf("expected response header %q, got %q", id, w.Header().Get(HeaderRequestID))
+	}
+	if _, id2 := serveRequestID(m, HeaderRequestID, ""); id2 == id {
+		t.Errorf("expected unique request ID, got %q twice", id)
+	}
+
+	w, id = serveRequestID(m, HeaderRequestID, "abc-123")
+	if id != "abc-123" || w.Header().Get(HeaderRequestID) != "abc-123" {
+		t.Errorf("expected incoming request ID, got %q", id)
+	}
+
+	for _, invalid := range []string{"foo bar", "foo\x00", strings.Repeat("a", maxRequestIDLength+1)} {
+		if _, id = serveRequestID(m, HeaderRequestID, invalid); id == invalid {
+			t.Errorf("expected invalid request ID %q to be replaced", invalid)
+		}
+	}
+}
+
+func TestRequestIDOptions(t *testing.T) {
+	m := &RequestID{
+		Header: "X-Trace-Id",
+		Generator: func() string {
+			return "generated"
+		},
+	}
+
+	w, id := serveRequestID(m, "X-Trace-Id", "")
+	if id != "generated" || w.Header().Get("X-Trace-Id") != "generated" {
+		t.Errorf("expected generated request ID, got %q", id)
+	}
+
+	_, id = serveRequestID(m, "X-Trace-Id", "incoming")
+	if id != "incoming" {
+		t.Errorf("expected incoming request ID, got %q", id)
+	}
+
+	_, id = serveRequestID(m, HeaderRequestID, "other")
+	if id != "generated" {
+		t.Errorf("expected the other header to be ignored, got %q", id)
+	}
+}
+
+func TestRequestIDPropagation(t *testing.T) {
+	logs := &bytes.Buffer{}
+	srv := New("")
+	srv.SetLogger(log.New(logs, 0, log.LevelAll))
+
+	accessLogs := &bytes.Buffer{}
+	accessLog := NewAccessLog("{{.RequestID}}")
+	accessLog.Output = accessLogs
+
+	router := NewRouter()
+	router.Use(&RequestID{Generator: func() string { return "foo" }})
+	router.Use(accessLog)
+	router.GET("/", func(ctx *Context) {
+		ctx.Logger().Info("hello")
+	})
+
+	req := httptest.NewRequest(MethodGet, "/", nil)
+	router.Handler().Handle(newContext(srv, httptest.NewRecorder(), req))
+
+	if !strings.Contains(logs.String(), "request_id=foo") {
+		t.Errorf("expected request ID in logs, got %q", logs.String())
+	}
+	if accessLogs.String() != "foo\n" {
+		t.Errorf("expected access log %q, got %q", "foo\n", accessLogs.String())
+	}
+}
+
+func TestContext_RequestID(t *testing.T) {
+	req := httptest.NewRequest(MethodGet, "/", nil)
+	req.Header.Set(HeaderRequestID, "header")
+	ctx := &Context{Request: req, server: New("")}
+	if ctx.RequestID() != "header" {
+		t.Errorf("expected request ID %q, got %q", "header", ctx.RequestID())
+	}
+
+	logger := ctx.Logger()
+	ctx.SetRequestID("foo")
+	if ctx.RequestID() != "foo" {
+		t.Errorf("expected request ID %q, got %q", "foo", ctx.RequestID())
+	}
+	if ctx.Logger() == logger {
+		t.Error("expected the logger to be rebuilt")
+	}
+
+	ctx = &Context{Request: &http.Request{Header: http.Header{}}}
+	if ctx.RequestID() != "" {
+		t.Errorf("expected empty request ID, got %q", ctx.RequestID())
+	}
+
+	ctx.Request.Header.Set(HeaderRequestID, "foo\nbar")
+	if ctx.RequestID() != "" {
+		t.Errorf("expected invalid request ID to be ignored, got %q", ctx.RequestID())
+	}
+}
@@ -0,0 +1,235 @@
+// Copyright 2016 The Gem Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file.
+
+package gem
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+)
+
+var errNotSupportHijack = errors.New("the response does not support hijacking")
+
+// NewResponseWriter returns a ResponseWriter that wraps w.
+func NewResponseWriter(w http.ResponseWriter) *ResponseWriter {
+	return &ResponseWriter{ResponseWriter: w}
+}
+
+// ResponseWriter wraps http.ResponseWriter, it records the status code
+// and size of response, so that the middlewares are able to inspect the
+// response after the handler returned.
+//
+// The Server installs a ResponseWriter as the Context.Response of each
+// request, see Context.ResponseWriter, the installed one implements
+// http.Flusher only if the underlying http.ResponseWriter does.
+type ResponseWriter struct {
+	http.ResponseWriter
+
+	status  int
+	size    int64
+	written bool
+	before  []func(*ResponseWriter)
+}
+
+// reset resets w to write the response via rw, the registered
+// functions are discarded.
+func (w *ResponseWriter) reset(rw http.ResponseWriter) {
+	for i := range w.before {
+		w.before[i] = nil
+	}
+
+	w.ResponseWriter = rw
+	w.status = 0
+	w.size = 0
+	w.written = false
+	w.before = w.before[:0]
+}
+
+// Status returns the status code of response,
+// zero if the header has not been written yet.
+func (w *ResponseWriter) Status() int {
+	return w.status
+}
+
+// Size returns the number of bytes of response body
+// that have been written.
+func (w *ResponseWriter) Size() int64 {
+	return w.size
+}
+
+// Written reports whether the header has been written.
+func (w *ResponseWriter) Written() bool {
+	return w.written
+}
+
+// Before registers a function that will be invoked right before the
+// header is written, the functions are invoked in order, it is useful
+// to modify the header in middlewares, such as:
+//
+//	ctx.ResponseWriter().Before(func(w *gem.ResponseWriter) {
+//		w.Header().Set("X-Response-Time", time.Since(start).String())
+//	})
+func (w *ResponseWriter) Before(f func(*ResponseWriter)) {
+	w.before = append(w.before, f)
+}
+
+// WriteHeader implements the http.ResponseWriter interface, the
+// subsequent calls are ignored once the header has been written.
+func (w *ResponseWriter) WriteHeader(code int) {
+	if w.written {
+		return
+	}
+
+	for _, f := range w.before {
+		f(w)
+	}
+
+	w.status = code
+	w.written = true
+	w.ResponseWriter.WriteHeader(code)
+}
+
+// Write implements the http.ResponseWriter interface, the status
+// code 200 is written if the header has not been written yet.
+func (w *ResponseWriter) Write(p []byte) (int, error) {
+	if !w.written {
+		w.WriteHeader(http.StatusOK)
+	}
+
+	n, err := w.ResponseWriter.Write(p)
+	w.size += int64(n)
+	return n, err
+}
+
+// Unwrap returns the underlying http.ResponseWriter.
+func (w *ResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// ReadFrom implements the io.ReaderFrom interface, it delegates to the
+// underlying http.ResponseWriter if possible, so that the sendfile fast
+// path is kept, such as serving files via http.FileServer.
+func (w *ResponseWriter) ReadFrom(r io.Reader) (n int64, err error) {
+	if !w.written {
+		w.WriteHeader(http.StatusOK)
+	}
+
+	if rf, ok := w.ResponseWriter.(io.ReaderFrom); ok {
+		n, err = rf.ReadFrom(r)
+	} else {
+		// hide the ReadFrom method of w to avoid recursion.
+		n, err = io.Copy(struct{ io.Writer }{w.ResponseWriter}, r)
+	}
+	w.size += n
+	return n, err
+}
+
+// Push implements the http.Pusher interface, errNotSupportHTTP2ServerPush
+// is returned if the underlying http.ResponseWriter is not a http.Pusher.
+func (w *ResponseWriter) Push(target string, opts *http.PushOptions) error {
+	if pusher, ok := w.ResponseWriter.(http.Pusher); ok {
+		return pusher.Push(target, opts)
+	}
+
+	return errNotSupportHTTP2ServerPush
+}
+
+// Hijack implements the http.Hijacker interface, errNotSupportHijack
+// is returned if the underlying http.ResponseWriter is not a http.Hijacker.
+func (w *ResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errNotSupportHijack
+	}
+
+	conn, rw, err := hijacker.Hijack()
+	if err == nil {
+		w.written = true
+	}
+	return conn, rw, err
+}
+
+// flushResponseWriter is a ResponseWriter that implements http.Flusher,
+// it is used only if the underlying http.ResponseWriter is a http.Flusher,
+// so that the type assertion of http.Flusher is reliable.
+type flushResponseWriter struct {
+	*ResponseWriter
+}
+
+// Flush implements the http.Flusher interface.
+func (w flushResponseWriter) Flush() {
+	if !w.written {
+		w.WriteHeader(http.StatusOK)
+	}
+	w.ResponseWriter.ResponseWriter.(http.Flusher).Flush()
+}
+
+// Unwrap returns the ResponseWriter.
+func (w flushResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// installResponseWriter sets ctx.Response to the ResponseWriter that
+// wraps w, it is called once when the request starts. The w is used
+// directly if it is a ResponseWriter already, such as the request is
+// passed from the parent router.
+func (ctx *Context) installResponseWriter(w http.ResponseWriter) {
+	if rw := findResponseWriter(w); rw != nil {
+		ctx.rw = rw
+		ctx.Response = w
+		return
+	}
+
+	ctx.response.reset(w)
+	ctx.rw = &ctx.response
+	if _, ok := w.(http.Flusher); ok {
+		ctx.Response = flushResponseWriter{ctx.rw}
+	} else {
+		ctx.Response = ctx.rw
+	}
+}
+
+// findResponseWriter unwraps w via the "Unwrap() http.ResponseWriter"
+// method until a ResponseWriter is found, nil is returned if none.
+func findResponseWriter(w http.ResponseWriter) *ResponseWriter {
+	for w != nil {
+		if rw, ok := w.(*ResponseWriter); ok {
+			return rw
+		}
+		unwrapper, ok := w.(interface{ Unwrap() http.ResponseWriter })
+		if !ok {
+			break
+		}
+		w = unwrapper.Unwrap()
+	}
+
+	return nil
+}
+
+// ResponseWriter returns the ResponseWriter of ctx.Response, the
+// ctx.Response is unwrapped via the "Unwrap() http.ResponseWriter"
+// method if it was replaced by the middlewares. If none is found, the
+// ResponseWriter that was installed when the request started is
+// returned.
+func (ctx *Context) ResponseWriter() *ResponseWriter {
+	if rw := findResponseWriter(ctx.Response); rw != nil {
+		return rw
+	}
+	if ctx.rw != nil {
+		return ctx.rw
+	}
+
+	// the ctx was not created by the Server or Router, the returned
+	// ResponseWriter does not track ctx.Response.
+	return &ctx.response
+}
+
+// Written reports whether the response header has been written,
+// see ResponseWriter.Written.
+func (ctx *Context) Written() bool {
+	return ctx.ResponseWriter().Written()
+}
@@ -0,0 +1,186 @@
+// Copyright 2016 The Gem Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file.
+
+package gem
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestResponseWriter(t *testing.T) {
+	rec := httptest.NewRecorder()
+	w := NewResponseWriter(rec)
+	if w.Written() || w.Status() != 0 || w.Size() != 0 {
+		t.Fatalf("unexpected initial state: written %t, status %d, size %d", w.Written(), w.Status(), w.Size())
+	}
+
+	var calls []string
+	w.Before(func(w *ResponseWriter) {
+		calls = append(calls, "first")
+		w.Header().Set("X-Foo", "bar")
+	})
+	w.Before(func(w *ResponseWriter) {
+		calls = append(calls, "second")
+	})
+
+	w.WriteHeader(http.StatusCreated)
+	w.WriteHeader(http.StatusInternalServerError)
+	w.Write([]byte("hello"))
+	w.Write([]byte(" world"))
+
+	if len(calls) != 2 || calls[0] != "first" || calls[1] != "second" {
+		t.Errorf("unexpected before calls %v", calls)
+	}
+	if !w.Written() || w.Status() != http.StatusCreated || w.Size() != 11 {
+		t.Errorf("unexpected state: written %t, status %d, size %d", w.Written(), w.Status(), w.Size())
+	}
+	if rec.Code != http.StatusCreated || rec.Header().Get("X-Foo") != "bar" || rec.Body.String() != "hello world" {
+		t.Errorf("unexpected response: %d %v %q", rec.Code, rec.Header(), rec.Body.String())
+	}
+	if w.Unwrap() != rec {
+		t.Error("Unwrap did not return the underlying response writer")
+	}
+
+	w = NewResponseWriter(httptest.NewRecorder())
+	w.Write([]byte("foo"))
+	if w.Status() != http.StatusOK {
+		t.Errorf("expected status code %d, got %d", http.StatusOK, w.Status())
+	}
+}
+
+type mockHijacker struct {
+	*mockResponseWriter
+}
+
+func (w *mockHijacker) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return nil, nil, nil
+}
+
+func TestResponseWriterInterfaces(t *testing.T) {
+	w := NewResponseWriter(&mockResponseWriter{})
+	if err := w.Push("/foo", nil); err != errNotSupportHTTP2ServerPush {
+		t.Errorf("expected error %q, got %v", errNotSupportHTTP2ServerPush, err)
+	}
+	if _, _, err := w.Hijack(); err != errNotSupportHijack {
+		t.Errorf("expected error %q, got %v", errNotSupportHijack, err)
+	}
+
+	w = NewResponseWriter(&mockResponseWriter2{&mockResponseWriter{}})
+	if err := w.Push("/foo", nil); err != nil {
+		t.Errorf("expected nil error, got %s", err)
+	}
+
+	w = NewResponseWriter(&mockHijacker{&mockResponseWriter{}})
+	if _, _, err := w.Hijack(); err != nil || !w.Written() {
+		t.Errorf("unexpected hijack result: error %v, written %t", err, w.Written())
+	}
+
+	// the installed ResponseWriter is a http.Flusher only if the underlying one is.
+	ctx := newContext(nil, &mockResponseWriter{}, nil)
+	if _, ok := ctx.Response.(http.Flusher); ok {
+		t.Error("expected non-flusher")
+	}
+	rec := httptest.NewRecorder()
+	ctx = newContext(nil, rec, nil)
+	ctx.Response.(http.Flusher).Flush()
+	if rw := ctx.ResponseWriter(); !rec.Flushed || !rw.Written() || rw.Status() != http.StatusOK {
+		t.Errorf("unexpected state: flushed %t, written %t, status %d", rec.Flushed, rw.Written(), rw.Status())
+	}
+}
+
+// readerFromRecorder records whether ReadFrom was called.
+type readerFromRecorder struct {
+	*httptest.ResponseRecorder
+	readFrom bool
+}
+
+func (w *readerFromRecorder) ReadFrom(r io.Reader) (int64, error) {
+	w.readFrom = true
+	return io.Copy(w.ResponseRecorder, r)
+}
+
+func TestResponseWriterReadFrom(t *testing.T) {
+	rec := &readerFromRecorder{ResponseRecorder: httptest.NewRecorder()}
+	w := NewResponseWriter(rec)
+	if n, err := w.ReadFrom(strings.NewReader("hello")); n != 5 || err != nil {
+		t.Fatalf("unexpected result %d, %v", n, err)
+	}
+	if !rec.readFrom || !w.Written() || w.Status() != http.StatusOK || w.Size() != 5 || rec.Body.String() != "hello" {
+		t.Errorf("expected ReadFrom to be delegated, got %t %t %d %d %q", rec.readFrom, w.Written(), w.Status(), w.Size(), rec.Body.String())
+	}
+
+	// falls back to copying if the underlying writer is not an io.ReaderFrom.
+	plain := httptest.NewRecorder()
+	w = NewResponseWriter(plain)
+	if n, err := io.Copy(w, strings.NewReader("world")); n != 5 || err != nil {
+		t.Fatalf("unexpected result %d, %v", n, err)
+	}
+	if w.Size() != 5 || plain.Body.String() != "world" {
+		t.Errorf("unexpected state: size %d, body %q", w.Size(), plain.Body.String())
+	}
+}
+
+type mockUnwrapper struct {
+	http.ResponseWriter
+}
+
+func (w *mockUnwrapper) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func TestContext_ResponseWriter(t *testing.T) {
+	resp := &mockResponseWriter{}
+	ctx := newContext(New(""), resp, nil)
+	w := ctx.ResponseWriter()
+	if ctx.Response != w || w.Unwrap() != resp {
+		t.Fatal("expected the response to be wrapped when the request started")
+	}
+	if ctx.ResponseWriter() != w {
+		t.Error("expected the same response writer")
+	}
+
+	ctx.Response = &mockUnwrapper{w}
+	if ctx.ResponseWriter() != w {
+		t.Error("expected the response writer to be unwrapped")
+	}
+
+	// the getter does not replace the ctx.Response.
+	replaced := &mockResponseWriter{}
+	ctx.Response = replaced
+	if ctx.ResponseWriter() != w || ctx.Response != replaced {
+		t.Error("expected the installed response writer")
+	}
+	ctx.Response = w
+
+	if ctx.Written() {
+		t.Error("expected the response has not been written")
+	}
+	ctx.Write([]byte("foo"))
+	if !ctx.Written() {
+		t.Error("expected the response has been written")
+	}
+
+	// JSON and XML should not write the header again after written.
+	ctx.JSON(http.StatusOK, make(chan struct{}))
+	ctx.XML(http.StatusOK, make(chan struct{}))
+	if w.Status() != http.StatusOK || resp.statusCode != http.StatusOK {
+		t.Errorf("expected status code %d, got %d", http.StatusOK, resp.statusCode)
+	}
+}
+
+func TestServerResponseWriter(t *testing.T) {
+	srv := New("")
+	srv.init(HandlerFunc(func(ctx *Context) {
+		if findResponseWriter(ctx.Response) != &ctx.response {
+			t.Errorf("expected *ResponseWriter, got %T", ctx.Response)
+		}
+	}))
+	srv.Server.Handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(MethodGet, "/", nil))
+}
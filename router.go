@@ -5,7 +5,15 @@
 package gem
 
 import (
+	"encoding"
+	"fmt"
+	"io/fs"
 	"net/http"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
 )
 
 // Router is a http.Handler which can be used to dispatch requests to different
@@ -13,8 +21,29 @@ import (
 type Router struct {
 	trees map[string]*node
 
+	// routes maps route's name to its path.
+	routes map[string]string
+
+	// renderers maps media type to the custom renderer.
+	renderers map[string]Renderer
+
+	// templates is used to render templates via Context.Render.
+	templates *Templates
+
 	middlewares []Middleware
 
+	// maxParams is the maximum number of parameters of all routes,
+	// it is used to pre-size the parameters of Context.
+	maxParams int
+
+	// httpHandler is the handler of ServeHTTP, which is built once.
+	httpHandler     Handler
+	httpHandlerOnce sync.Once
+
+	// contextPool reuses the Context of the requests served by ServeHTTP,
+	// see Server.SetReuseContext.
+	contextPool sync.Pool
+
 	// Enables automatic redirection if the current route can't be matched but a
 	// handler for the path with (without) the trailing slash exists.
 	// For example if /foo/ is requested but a route only exists for /foo, the
@@ -62,6 +91,18 @@ type Router struct {
 	// The handler can be used to keep your server from crashing because of
 	// unrecovered panics.
 	PanicHandler func(*Context, interface{})
+
+	// ErrorHandler handles the errors returned by handlers, see
+	// Context.HandleError. If it is not set, DefaultErrorHandler is used.
+	ErrorHandler func(*Context, error)
+
+	// If enabled, the DefaultErrorHandler renders errors in the problem
+	// details format of RFC 7807, such as "application/problem+json".
+	ProblemDetails bool
+
+	// WebSocketUpgrader is used by Context.Upgrade and Router.WebSocket,
+	// the default upgrader is used if it is not set.
+	WebSocketUpgrader *WebSocketUpgrader
 }
 
 // NewRouter returns a new initialized Router.
@@ -123,6 +164,25 @@ func (r *Router) DELETE(path string, handle HandlerFunc, opts ...*HandlerOption)
 // This function is intended for bulk loading and to allow the usage of less
 // frequently used, non-standardized or custom methods (e.g. for internal
 // communication with a proxy).
+//
+// The value of parameter can be constrained by one of the built-in types
+// int, uint, alpha, alnum and uuid, or a regular expression which must match
+// the whole value, the request would not be routed to the handle if the
+// constraint is not satisfied:
+//     router.GET("/users/:id<int>", handle)
+//     router.GET("/posts/:slug<[a-z0-9-]+>", handle)
+//
+// Handlers that return error can be registered via E, the returned error
+// is handled by Context.HandleError:
+//
+//	router.GET("/users/:id", gem.E(func(ctx *gem.Context) error {
+//		id, err := ctx.ParamInt64("id")
+//		if err != nil {
+//			return err
+//		}
+//		// ...
+//		return nil
+//	}))
 func (r *Router) Handle(method, path string, handle HandlerFunc, opts ...*HandlerOption) {
 	if path[0] != '/' {
 		panic("path must begin with '/' in path '" + path + "'")
@@ -147,7 +207,28 @@ func (r *Router) Handle(method, path string, handle HandlerFunc, opts ...*Handle
 		}
 	}
 
-	root.addRoute(path, handler)
+	root.addRoute(path, routeHandler{route: path, handler: handler})
+
+	if n := int(countParams(path)); n > r.maxParams {
+		r.maxParams = n
+	}
+
+	if len(opts) > 0 && opts[0].Name != "" {
+		r.setName(opts[0].Name, path)
+	}
+}
+
+// routeHandler records the matched route's path into context.
+type routeHandler struct {
+	route   string
+	handler Handler
+}
+
+func (h routeHandler) Handle(ctx *Context) {
+	if ctx != nil {
+		ctx.setRoute(h.route)
+	}
+	h.handler.Handle(ctx)
 }
 
 // ServeFiles serves files from the given file system root.
@@ -168,14 +249,144 @@ func (r *Router) ServeFiles(path string, root http.FileSystem, opts ...*HandlerO
 	fileServer := http.FileServer(root)
 
 	handle := func(ctx *Context) {
-		filepath, _ := ctx.UserValue("filepath").(string)
-		ctx.Request.URL.Path = filepath
+		ctx.Request.URL.Path = ctx.Param("filepath")
 		fileServer.ServeHTTP(ctx.Response, ctx.Request)
 	}
 
 	r.GET(path, handle, opts...)
 }
 
+// ServeFS serves files from the given fs.FS, such as an embed.FS,
+// the path must end with "/*filepath" as well:
+//
+//	router.ServeFS("/static/*filepath", assets)
+//
+// See Router.ServeFiles.
+func (r *Router) ServeFS(path string, fsys fs.FS, opts ...*HandlerOption) {
+	r.ServeFiles(path, http.FS(fsys), opts...)
+}
+
+// SetTemplates set the templates manager which is used by Context.Render,
+// the "url" function of templates generates URL via the router as well.
+func (r *Router) SetTemplates(templates *Templates) {
+	r.templates = templates
+	if templates != nil {
+		templates.router = r
+	}
+}
+
+func (r *Router) setName(name, path string) {
+	if r.routes == nil {
+		r.routes = make(map[string]string)
+	}
+
+	if _, ok := r.routes[name]; ok {
+		panic("a route named '" + name + "' is already registered")
+	}
+
+	r.routes[name] = path
+}
+
+// URL generates the URL of the route with the given name, the params
+// are used to fill in the route's parameters in order, each of them
+// must be a string, []byte, integer, encoding.TextMarshaler or
+// fmt.Stringer, such as UUID.
+//
+// For example, if the route "user" was registered with path
+// "/users/:name/*filepath":
+//     router.URL("user", "foo", "avatar.png") // "/users/foo/avatar.png"
+func (r *Router) URL(name string, params ...interface{}) (string, error) {
+	path, ok := r.routes[name]
+	if !ok {
+		return "", fmt.Errorf("no route named %q", name)
+	}
+
+	return buildPath(path, params)
+}
+
+// formatParam converts the value of route's parameter to string.
+func formatParam(v interface{}) (string, error) {
+	switch value := v.(type) {
+	case string:
+		return value, nil
+	case []byte:
+		return string(value), nil
+	case encoding.TextMarshaler:
+		text, err := value.MarshalText()
+		return string(text), err
+	case fmt.Stringer:
+		return value.String(), nil
+	}
+
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(rv.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.FormatUint(rv.Uint(), 10), nil
+	}
+
+	return "", fmt.Errorf("unsupported type %T", v)
+}
+
+// buildPath replaces the parameters of path with the given params.
+func buildPath(path string, params []interface{}) (string, error) {
+	buf := make([]byte, 0, len(path))
+	var n int
+
+	for i := 0; i < len(path); i++ {
+		c := path[i]
+		if c != ':' && c != '*' {
+			buf = append(buf, c)
+			continue
+		}
+
+		// find wildcard end (either '/' or path end)
+		name, constraint, end := scanWildcard(path, i)
+
+		if n >= len(params) {
+			return "", fmt.Errorf("missing value of parameter %q for path %q", name, path)
+		}
+
+		value, err := formatParam(params[n])
+		if err != nil {
+			return "", fmt.Errorf("invalid value of parameter %q: %s", name, err)
+		}
+		n++
+
+		if c == ':' {
+			if value == "" {
+				return "", fmt.Errorf("empty value of parameter %q for path %q", name, path)
+			}
+			if constraint != "" {
+				match, err := compileConstraint(constraint)
+				if err != nil {
+					return "", err
+				}
+				if !match(value) {
+					return "", fmt.Errorf("value %q does not satisfy the constraint %q of parameter %q", value, constraint, name)
+				}
+			}
+			buf = append(buf, url.PathEscape(value)...)
+		} else {
+			// catchAll, the leading '/' has been included in path.
+			segments := strings.Split(strings.TrimPrefix(value, "/"), "/")
+			for j, segment := range segments {
+				segments[j] = url.PathEscape(segment)
+			}
+			buf = append(buf, strings.Join(segments, "/")...)
+		}
+
+		i = end - 1
+	}
+
+	if n < len(params) {
+		return "", fmt.Errorf("too many parameters for path %q, expected %d, got %d", path, n, len(params))
+	}
+
+	return string(buf), nil
+}
+
 func (r *Router) recv(ctx *Context) {
 	if rcv := recover(); rcv != nil {
 		r.PanicHandler(ctx, rcv)
@@ -241,7 +452,15 @@ func (r *Router) Handler() Handler {
 		handler = r.middlewares[i].Wrap(handler)
 	}
 
-	return handler
+	return HandlerFunc(func(ctx *Context) {
+		ctx.router = r
+		if size := ctx.inheritedParams + r.maxParams; cap(ctx.params) < size {
+			params := make(PathParams, len(ctx.params), size)
+			copy(params, ctx.params)
+			ctx.params = params
+		}
+		handler.Handle(ctx)
+	})
 }
 
 func (r *Router) handle(ctx *Context) {
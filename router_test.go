@@ -11,6 +11,7 @@ import (
 	"net/http/httptest"
 	"reflect"
 	"testing"
+	"testing/fstest"
 )
 
 type mockResponseWriter struct {
@@ -509,6 +510,19 @@ func TestRouterServeFiles(t *testing.T) {
 	}
 }
 
+func TestRouterServeFS(t *testing.T) {
+	router := NewRouter()
+	fsys := fstest.MapFS{"css/main.css": {Data: []byte("body{}")}}
+
+	router.ServeFS("/static/*filepath", fsys)
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest(MethodGet, "/static/css/main.css", nil)
+	router.handle(newContext(nil, w, r))
+	if w.Code != http.StatusOK || w.Body.String() != "body{}" {
+		t.Errorf("failed to serve file from fs.FS, got %d %q", w.Code, w.Body.String())
+	}
+}
+
 type testMiddleware struct {
 	handled bool
 }
@@ -558,3 +572,146 @@ func TestRouter_Handle(t *testing.T) {
 		t.Error("use handler option failed")
 	}
 }
+
+type testStringer string
+
+func (s testStringer) String() string {
+	return "s-" + string(s)
+}
+
+func TestRouter_URL(t *testing.T) {
+	handlerFunc := func(_ *Context) {}
+
+	router := NewRouter()
+	router.GET("/", handlerFunc, NewNamedHandlerOption("index"))
+	router.GET("/users/:name", handlerFunc, NewNamedHandlerOption("user"))
+	router.GET("/users/:name/files/*filepath", handlerFunc, NewNamedHandlerOption("file"))
+	router.GET("/anonymous", handlerFunc, NewHandlerOption())
+
+	tests := []struct {
+		name   string
+		params []interface{}
+		url    string
+		err    bool
+	}{
+		{"index", nil, "/", false},
+		{"user", []interface{}{"foo"}, "/users/foo", false},
+		{"user", []interface{}{2016}, "/users/2016", false},
+		{"user", []interface{}{uint8(7)}, "/users/7", false},
+		{"user", []interface{}{int32(-1)}, "/users/-1", false},
+		{"user", []interface{}{[]byte("foo")}, "/users/foo", false},
+		{"user", []interface{}{UUID{0x6b, 0xa7, 0xb8, 0x10}}, "/users/6ba7b810-0000-0000-0000-000000000000", false},
+		{"user", []interface{}{testStringer("foo")}, "/users/s-foo", false},
+		{"user", []interface{}{"foo bar"}, "/users/foo%20bar", false},
+		{"file", []interface{}{"foo", "/css/app.css"}, "/users/foo/files/css/app.css", false},
+		{"file", []interface{}{"foo", "css/a b.css"}, "/users/foo/files/css/a%20b.css", false},
+		{"user", nil, "", true},                         // missing parameter
+		{"user", []interface{}{""}, "", true},           // empty parameter
+		{"user", []interface{}{false}, "", true},        // unsupported type
+		{"user", []interface{}{"foo", "bar"}, "", true}, // too many parameters
+		{"anonymous", nil, "", true},                    // nonexistent route
+	}
+	for _, test := range tests {
+		url, err := router.URL(test.name, test.params...)
+		if test.err {
+			if err == nil {
+				t.Errorf("expected non-nil error for route %q with params %v, got nil", test.name, test.params)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("expected nil error for route %q with params %v, got %q", test.name, test.params, err)
+		}
+		if url != test.url {
+			t.Errorf("expected url %q, got %q", test.url, url)
+		}
+	}
+
+	recv := catchPanic(func() {
+		router.GET("/user", handlerFunc, NewNamedHandlerOption("user"))
+	})
+	if recv == nil {
+		t.Error("registering duplicate route name did not panic")
+	}
+}
+
+func TestRouterConstraint(t *testing.T) {
+	router := NewRouter()
+	router.GET("/users/:id<int>", func(ctx *Context) {
+		ctx.Response.WriteHeader(http.StatusOK)
+	}, NewNamedHandlerOption("user"))
+	router.DELETE("/posts/:id<int>", func(ctx *Context) {})
+
+	tests := []struct {
+		method string
+		path   string
+		code   int
+	}{
+		{MethodGet, "/users/1", http.StatusOK},
+		{MethodGet, "/users/foo", http.StatusNotFound},
+		{MethodGet, "/posts/1", http.StatusMethodNotAllowed},
+		{MethodGet, "/posts/foo", http.StatusNotFound},
+	}
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		r, _ := http.NewRequest(test.method, test.path, nil)
+		router.Handler().Handle(newContext(nil, w, r))
+		if w.Code != test.code {
+			t.Errorf("expected status code %d for %s %s, got %d", test.code, test.method, test.path, w.Code)
+		}
+	}
+
+	if url, err := router.URL("user", 2016); err != nil || url != "/users/2016" {
+		t.Errorf("expected url %q, got %q, %v", "/users/2016", url, err)
+	}
+	if _, err := router.URL("user", "foo"); err == nil {
+		t.Error("expected non-nil error for the value does not satisfy the constraint, got nil")
+	}
+}
+
+func newBenchmarkServer() *Server {
+	router := NewRouter()
+	router.GET("/", func(ctx *Context) {})
+	router.GET("/users/:name/posts/:id", func(ctx *Context) {
+		_ = ctx.Param("id")
+	})
+
+	srv := New("")
+	srv.SetReuseContext(true)
+	srv.init(router.Handler())
+	return srv
+}
+
+func TestRouterZeroAllocs(t *testing.T) {
+	srv := newBenchmarkServer()
+	w := new(mockResponseWriter)
+	for _, path := range []string{"/", "/users/gopher/posts/1"} {
+		req, _ := http.NewRequest(MethodGet, path, nil)
+		allocs := testing.AllocsPerRun(100, func() {
+			srv.Server.Handler.ServeHTTP(w, req)
+		})
+		if allocs > 0 {
+			t.Errorf("%s: expected zero allocations, got %v", path, allocs)
+		}
+	}
+}
+
+func benchmarkRouter(b *testing.B, path string) {
+	srv := newBenchmarkServer()
+	w := new(mockResponseWriter)
+	req, _ := http.NewRequest(MethodGet, path, nil)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		srv.Server.Handler.ServeHTTP(w, req)
+	}
+}
+
+func BenchmarkRouterStatic(b *testing.B) {
+	benchmarkRouter(b, "/")
+}
+
+func BenchmarkRouterParam(b *testing.B) {
+	benchmarkRouter(b, "/users/gopher/posts/1")
+}
@@ -5,7 +5,13 @@
 package gem
 
 import (
+	"context"
 	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
 )
 
 const version = "2.1.0"
@@ -21,14 +27,26 @@ func New(addr string) *Server {
 		Server: &http.Server{
 			Addr: addr,
 		},
-		logger: defaultLogger,
+		logger:          defaultLogger,
+		shutdownTimeout: defaultShutdownTimeout,
 	}
 }
 
+// defaultShutdownTimeout is the default maximum duration of waiting
+// for the active connections to become idle in Run and RunTLS.
+const defaultShutdownTimeout = 30 * time.Second
+
 // Server contains *http.Server.
 type Server struct {
 	Server *http.Server
 	logger Logger
+
+	shutdownTimeout   time.Duration
+	shutdownCallbacks []func()
+
+	// contextPool reuses the Context of requests if reuseContext is set.
+	reuseContext bool
+	contextPool  sync.Pool
 }
 
 // SetLogger set logger.
@@ -36,6 +54,119 @@ func (srv *Server) SetLogger(logger Logger) {
 	srv.logger = logger
 }
 
+// SetShutdownTimeout set the maximum duration of waiting for the
+// active connections to become idle in Run and RunTLS, non-positive
+// timeout means waiting forever.
+func (srv *Server) SetShutdownTimeout(timeout time.Duration) {
+	srv.shutdownTimeout = timeout
+}
+
+// SetReuseContext sets whether to reuse the Context of requests to avoid
+// allocations, it is disabled by default.
+//
+// The reused Context is reset once the handler returned, so it must not
+// be retained after that, such as passed to the goroutines or libraries
+// that outlive the request, since the Context is a context.Context, pass
+// ctx.Request.Context() to them instead:
+//
+//	router.GET("/jobs", func(ctx *gem.Context) {
+//		reqCtx := ctx.Request.Context()
+//		go work(reqCtx)
+//	})
+func (srv *Server) SetReuseContext(reuse bool) {
+	srv.reuseContext = reuse
+}
+
+// SetShutdownCallback set user-defined shutdown callback, the callbacks
+// will be invoked in order after the server has been shut down, such as:
+//
+//	srv.SetShutdownCallback(func() {
+//		app.Close()
+//	})
+func (srv *Server) SetShutdownCallback(callback func()) {
+	srv.shutdownCallbacks = append(srv.shutdownCallbacks, callback)
+}
+
+// Shutdown gracefully shuts down the server without interrupting any
+// active connections, see http.Server.Shutdown for more details.
+//
+// The shutdown callbacks are invoked after all of the connections
+// have been closed, or the ctx has expired.
+func (srv *Server) Shutdown(ctx context.Context) error {
+	err := srv.Server.Shutdown(ctx)
+
+	for _, callback := range srv.shutdownCallbacks {
+		callback()
+	}
+
+	return err
+}
+
+// Run acts identically to ListenAndServe, except that it shuts down
+// the server gracefully once received SIGINT or SIGTERM signal.
+//
+// A nil error would be returned if the server has been shut down
+// successfully.
+func (srv *Server) Run(handler Handler) error {
+	srv.init(handler)
+
+	return srv.runWithSignals(srv.Server.ListenAndServe)
+}
+
+// RunTLS acts identically to Run, except that it expects
+// HTTPS connections.
+func (srv *Server) RunTLS(certFile, keyFile string, handler Handler) error {
+	srv.init(handler)
+
+	return srv.runWithSignals(func() error {
+		return srv.Server.ListenAndServeTLS(certFile, keyFile)
+	})
+}
+
+func (srv *Server) runWithSignals(serve func() error) error {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(signals)
+
+	return srv.run(serve, signals)
+}
+
+func (srv *Server) run(serve func() error, signals <-chan os.Signal) error {
+	errs := make(chan error, 1)
+	go func() {
+		errs <- serve()
+	}()
+
+	select {
+	case err := <-errs:
+		if err == http.ErrServerClosed {
+			return nil
+		}
+		return err
+	case sig := <-signals:
+		if srv.logger != nil {
+			srv.logger.Infof("received signal %s, shutting down server", sig)
+		}
+	}
+
+	ctx := context.Background()
+	if srv.shutdownTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, srv.shutdownTimeout)
+		defer cancel()
+	}
+
+	if err := srv.Shutdown(ctx); err != nil {
+		return err
+	}
+
+	if err := <-errs; err != http.ErrServerClosed {
+		return err
+	}
+
+	return nil
+}
+
 // ListenAndServe listens on the TCP network address srv.Addr and then
 // calls Serve to handle requests on incoming connections.
 func (srv *Server) ListenAndServe(handler Handler) error {
@@ -55,11 +186,40 @@ func (srv *Server) ListenAndServeTLS(certFile, keyFile string, handler Handler)
 
 func (srv *Server) init(handler Handler) {
 	srv.Server.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		ctx := newContext(srv, w, r)
+		ctx := srv.acquireContext(w, r)
+		defer srv.releaseContext(ctx)
+
 		handler.Handle(ctx)
 	})
 }
 
+// acquireContext returns a Context to serve the given request, which is
+// taken from the pool if the Context is reused.
+func (srv *Server) acquireContext(w http.ResponseWriter, r *http.Request) *Context {
+	var ctx *Context
+	if srv.reuseContext {
+		ctx, _ = srv.contextPool.Get().(*Context)
+	}
+	if ctx == nil {
+		ctx = &Context{server: srv}
+	}
+	ctx.reset(w, r)
+
+	return ctx
+}
+
+// releaseContext finishes the request and puts the Context back to the
+// pool if the Context is reused.
+func (srv *Server) releaseContext(ctx *Context) {
+	ctx.finish()
+	if !srv.reuseContext {
+		return
+	}
+
+	ctx.reset(nil, nil)
+	srv.contextPool.Put(ctx)
+}
+
 // ListenAndServe listens on the TCP network address addr
 // and then calls Serve with handler to handle requests
 // on incoming connections.
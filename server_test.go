@@ -6,7 +6,16 @@ package gem
 
 import (
 	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"reflect"
+	"syscall"
 	"testing"
+	"time"
 )
 
 func TestServer_SetLogger(t *testing.T) {
@@ -40,3 +49,119 @@ func TestServerInit(t *testing.T) {
 		t.Errorf("expected response body %q, got %q", body, resp.body)
 	}
 }
+
+func TestServer_SetShutdownTimeout(t *testing.T) {
+	srv := New("")
+	if srv.shutdownTimeout != defaultShutdownTimeout {
+		t.Errorf("expected default shutdown timeout %s, got %s", defaultShutdownTimeout, srv.shutdownTimeout)
+	}
+
+	srv.SetShutdownTimeout(time.Second)
+	if srv.shutdownTimeout != time.Second {
+		t.Errorf("expected shutdown timeout %s, got %s", time.Second, srv.shutdownTimeout)
+	}
+}
+
+func TestServer_Shutdown(t *testing.T) {
+	var calls []int
+	srv := New("")
+	srv.SetShutdownCallback(func() { calls = append(calls, 1) })
+	srv.SetShutdownCallback(func() { calls = append(calls, 2) })
+
+	if err := srv.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(calls, []int{1, 2}) {
+		t.Errorf("expected callbacks to be invoked in order, got %v", calls)
+	}
+}
+
+func TestServerRun(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	started := make(chan struct{})
+	srv := New("")
+	srv.SetLogger(nil)
+	srv.init(HandlerFunc(func(ctx *Context) {
+		close(started)
+		time.Sleep(50 * time.Millisecond)
+		ctx.Response.Write([]byte("foo"))
+	}))
+
+	var closed bool
+	srv.SetShutdownCallback(func() { closed = true })
+
+	signals := make(chan os.Signal, 1)
+	result := make(chan error, 1)
+	go func() {
+		result <- srv.run(func() error { return srv.Server.Serve(ln) }, signals)
+	}()
+
+	body := make(chan string, 1)
+	go func() {
+		resp, err := http.Get("http://" + ln.Addr().String())
+		if err != nil {
+			body <- err.Error()
+			return
+		}
+		defer resp.Body.Close()
+		data, _ := ioutil.ReadAll(resp.Body)
+		body <- string(data)
+	}()
+
+	<-started
+	signals <- syscall.SIGTERM
+
+	if err = <-result; err != nil {
+		t.Errorf("expected nil error, got %s", err)
+	}
+	if !closed {
+		t.Error("shutdown callback was not invoked")
+	}
+	// the in-flight request should be completed.
+	if got := <-body; got != "foo" {
+		t.Errorf("expected response body %q, got %q", "foo", got)
+	}
+}
+
+func TestServerRunTimeout(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	started := make(chan struct{})
+	done := make(chan struct{})
+	defer close(done)
+	srv := New("")
+	srv.SetLogger(nil)
+	srv.SetShutdownTimeout(10 * time.Millisecond)
+	srv.init(HandlerFunc(func(ctx *Context) {
+		close(started)
+		<-done
+	}))
+
+	signals := make(chan os.Signal, 1)
+	result := make(chan error, 1)
+	go func() {
+		result <- srv.run(func() error { return srv.Server.Serve(ln) }, signals)
+	}()
+	go http.Get("http://" + ln.Addr().String())
+
+	<-started
+	signals <- os.Interrupt
+	if err = <-result; err != context.DeadlineExceeded {
+		t.Errorf("expected error %q, got %v", context.DeadlineExceeded, err)
+	}
+}
+
+func TestServerRunError(t *testing.T) {
+	srv := New("")
+	want := errors.New("listen error")
+	if err := srv.run(func() error { return want }, nil); err != want {
+		t.Errorf("expected error %q, got %v", want, err)
+	}
+}
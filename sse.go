@@ -0,0 +1,247 @@
+// Copyright 2016 The Gem Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file.
+
+package gem
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MIMEEventStream is the media type of Server-Sent Events.
+const MIMEEventStream = "text/event-stream"
+
+var (
+	errNotSupportFlush   = errors.New("the response does not support flushing")
+	errEventStreamClosed = errors.New("the event stream has been closed")
+	errInvalidEventField = errors.New("the id and event of event must not contain newlines")
+)
+
+// Event is a Server-Sent Event.
+type Event struct {
+	// ID is the event ID, the client sends it back via the
+	// Last-Event-ID header when reconnecting.
+	ID string
+
+	// Event is the event type, "message" is implied if empty.
+	Event string
+
+	// Data is the payload, the string and []byte are sent as-is,
+	// the others are encoded in JSON.
+	Data interface{}
+
+	// Retry is the reconnection time of the client, it is
+	// omitted if non-positive.
+	Retry time.Duration
+}
+
+// EventStream writes Server-Sent Events, see Context.SSE.
+//
+// The request's context, writer and flusher are captured when the stream
+// starts, so that the stream does not refer to the Context, which may be
+// reused by another request after the handler returned.
+type EventStream struct {
+	reqCtx      context.Context
+	w           io.Writer
+	flusher     http.Flusher
+	lastEventID string
+
+	mu     sync.Mutex
+	closed bool
+	stop   chan struct{}
+	wg     sync.WaitGroup
+}
+
+// SSE starts an event stream, the headers are written and flushed
+// immediately, each event is flushed once it is sent. The stream is
+// closed when the handler returns, it can be closed earlier via Close:
+//
+//	router.GET("/events", gem.E(func(ctx *gem.Context) error {
+//		stream, err := ctx.SSE()
+//		if err != nil {
+//			return err
+//		}
+//		defer stream.Close()
+//
+//		stream.Heartbeat(15 * time.Second)
+//		for {
+//			select {
+//			case <-stream.Done():
+//				return nil
+//			case update := <-updates:
+//				if err := stream.Send(gem.Event{ID: update.ID, Data: update}); err != nil {
+//					return err
+//				}
+//			}
+//		}
+//	}))
+//
+// An error would be returned if the response does not support flushing.
+func (ctx *Context) SSE() (*EventStream, error) {
+	flusher, ok := ctx.Response.(http.Flusher)
+	if !ok {
+		return nil, errNotSupportFlush
+	}
+
+	header := ctx.Response.Header()
+	header.Set("Content-Type", MIMEEventStream)
+	header.Set("Cache-Control", "no-cache")
+	// disable the buffering of nginx.
+	header.Set("X-Accel-Buffering", "no")
+	ctx.Response.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	s := &EventStream{
+		reqCtx:      ctx.Request.Context(),
+		w:           ctx.Response,
+		flusher:     flusher,
+		lastEventID: ctx.Request.Header.Get("Last-Event-ID"),
+		stop:        make(chan struct{}),
+	}
+	// stop the heartbeats once the handler returned.
+	ctx.onFinish(func() {
+		s.Close()
+	})
+
+	return s, nil
+}
+
+// LastEventID returns the Last-Event-ID header that is sent by the client
+// when reconnecting, the events after it should be sent to resume.
+func (s *EventStream) LastEventID() string {
+	return s.lastEventID
+}
+
+// Done returns a channel that is closed when the client disconnects.
+func (s *EventStream) Done() <-chan struct{} {
+	return s.reqCtx.Done()
+}
+
+// Send sends the event, an error would be returned if the client has
+// disconnected or the stream has been closed.
+func (s *EventStream) Send(event Event) error {
+	if strings.ContainsAny(event.ID, "\r\n") || strings.ContainsAny(event.Event, "\r\n") {
+		return errInvalidEventField
+	}
+
+	buf := &bytes.Buffer{}
+	if event.ID != "" {
+		buf.WriteString("id: " + event.ID + "\n")
+	}
+	if event.Event != "" {
+		buf.WriteString("event: " + event.Event + "\n")
+	}
+	if event.Retry > 0 {
+		buf.WriteString("retry: " + strconv.FormatInt(int64(event.Retry/time.Millisecond), 10) + "\n")
+	}
+
+	var data string
+	switch v := event.Data.(type) {
+	case nil:
+	case string:
+		data = v
+	case []byte:
+		data = string(v)
+	default:
+		b, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		data = string(b)
+	}
+	data = strings.ReplaceAll(strings.ReplaceAll(data, "\r\n", "\n"), "\r", "\n")
+	for _, line := range strings.Split(data, "\n") {
+		buf.WriteString("data: " + line + "\n")
+	}
+	buf.WriteByte('\n')
+
+	return s.write(buf.Bytes())
+}
+
+// Comment sends a comment, which is ignored by the client, it is
+// useful to keep the connection alive.
+func (s *EventStream) Comment(text string) error {
+	buf := &bytes.Buffer{}
+	for _, line := range strings.Split(text, "\n") {
+		buf.WriteString(": " + strings.TrimSuffix(line, "\r") + "\n")
+	}
+	buf.WriteByte('\n')
+
+	return s.write(buf.Bytes())
+}
+
+func (s *EventStream) write(p []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return errEventStreamClosed
+	}
+	if err := s.reqCtx.Err(); err != nil {
+		return err
+	}
+
+	if _, err := s.w.Write(p); err != nil {
+		return err
+	}
+	s.flusher.Flush()
+
+	return nil
+}
+
+// Heartbeat sends a comment periodically in the background to prevent
+// the proxies from closing the idle connection, until the client
+// disconnects, the stream is closed or the handler returned. The
+// non-positive interval is ignored.
+func (s *EventStream) Heartbeat(interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+
+	s.wg.Add(1)
+	done := s.Done()
+	go func() {
+		defer s.wg.Done()
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-s.stop:
+				return
+			case <-done:
+				return
+			case <-ticker.C:
+				if err := s.Comment("heartbeat"); err != nil {
+					return
+				}
+			}
+		}
+	}()
+}
+
+// Close closes the stream and waits for the heartbeats to stop, the
+// subsequent sends would fail.
+func (s *EventStream) Close() error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return nil
+	}
+	s.closed = true
+	close(s.stop)
+	s.mu.Unlock()
+
+	s.wg.Wait()
+	return nil
+}
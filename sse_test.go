@@ -0,0 +1,186 @@
+// Copyright 2016 The Gem Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file.
+
+package gem
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestContext_SSE(t *testing.T) {
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(MethodGet, "/events", nil)
+	req.Header.Set("Last-Event-ID", "41")
+	ctx := newContext(nil, w, req)
+
+	stream, err := ctx.SSE()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stream.Close()
+
+	if w.Code != http.StatusOK || !w.Flushed {
+		t.Errorf("expected the header to be flushed, got %d %t", w.Code, w.Flushed)
+	}
+
+	// the non-positive interval is ignored.
+	stream.Heartbeat(0)
+	stream.Heartbeat(-time.Second)
+	if w.Header().Get("Content-Type") != MIMEEventStream || w.Header().Get("Cache-Control") != "no-cache" {
+		t.Errorf("unexpected headers %v", w.Header())
+	}
+	if stream.LastEventID() != "41" {
+		t.Errorf("expected last event ID %q, got %q", "41", stream.LastEventID())
+	}
+
+	events := []Event{
+		{Data: "hello"},
+		{ID: "42", Event: "update", Data: map[string]int{"count": 1}, Retry: 3 * time.Second},
+		{Data: []byte("line1\r\nline2\nline3")},
+		{Event: "ping"},
+	}
+	for _, event := range events {
+		if err := stream.Send(event); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := stream.Comment("foo\nbar"); err != nil {
+		t.Fatal(err)
+	}
+
+	want := "data: hello\n\n" +
+		"id: 42\nevent: update\nretry: 3000\ndata: {\"count\":1}\n\n" +
+		"data: line1\ndata: line2\ndata: line3\n\n" +
+		"event: ping\ndata: \n\n" +
+		": foo\n: bar\n\n"
+	if w.Body.String() != want {
+		t.Errorf("expected body %q, got %q", want, w.Body.String())
+	}
+
+	if err := stream.Send(Event{ID: "1\n2"}); err != errInvalidEventField {
+		t.Errorf("expected error %q, got %v", errInvalidEventField, err)
+	}
+	if err := stream.Send(Event{Data: make(chan int)}); err == nil {
+		t.Error("expected JSON error")
+	}
+
+	stream.Close()
+	if err := stream.Send(Event{Data: "foo"}); err != errEventStreamClosed {
+		t.Errorf("expected error %q, got %v", errEventStreamClosed, err)
+	}
+	if err := stream.Close(); err != nil {
+		t.Errorf("expected nil error, got %s", err)
+	}
+}
+
+func TestContext_SSENotSupported(t *testing.T) {
+	req := httptest.NewRequest(MethodGet, "/events", nil)
+	for _, w := range []http.ResponseWriter{&mockResponseWriter{}, NewResponseWriter(&mockResponseWriter{})} {
+		ctx := newContext(nil, w, req)
+		if _, err := ctx.SSE(); err != errNotSupportFlush {
+			t.Errorf("%T: expected error %q, got %v", w, errNotSupportFlush, err)
+		}
+	}
+}
+
+func TestEventStreamHeartbeat(t *testing.T) {
+	done := make(chan error, 1)
+	router := NewRouter()
+	router.GET("/events", E(func(ctx *Context) error {
+		stream, err := ctx.SSE()
+		if err != nil {
+			return err
+		}
+		defer stream.Close()
+
+		stream.Heartbeat(10 * time.Millisecond)
+		if err := stream.Send(Event{ID: "1", Data: "hello"}); err != nil {
+			return err
+		}
+
+		<-stream.Done()
+		done <- stream.Send(Event{Data: "gone"})
+		return nil
+	}))
+
+	srv := New("")
+	srv.init(router.Handler())
+	ts := httptest.NewServer(srv.Server.Handler)
+	defer ts.Close()
+
+	reqCtx, cancel := context.WithCancel(context.Background())
+	req, _ := http.NewRequest(MethodGet, ts.URL+"/events", nil)
+	resp, err := http.DefaultClient.Do(req.WithContext(reqCtx))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.Header.Get("Content-Type") != MIMEEventStream {
+		t.Errorf("expected content type %q, got %q", MIMEEventStream, resp.Header.Get("Content-Type"))
+	}
+
+	var lines []string
+	reader := bufio.NewReader(resp.Body)
+	for len(lines) < 5 {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatal(err)
+		}
+		lines = append(lines, strings.TrimSuffix(line, "\n"))
+	}
+	want := []string{"id: 1", "data: hello", "", ": heartbeat", ""}
+	for i := range want {
+		if lines[i] != want[i] {
+			t.Fatalf("expected lines %q, got %q", want, lines)
+		}
+	}
+
+	// the stream should be stopped once the client disconnected.
+	cancel()
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Error("expected error after the client disconnected")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the stream was not stopped after the client disconnected")
+	}
+}
+
+func TestEventStreamHandlerReturned(t *testing.T) {
+	var stream *EventStream
+	router := NewRouter()
+	router.GET("/events", func(ctx *Context) {
+		var err error
+		if stream, err = ctx.SSE(); err != nil {
+			t.Fatal(err)
+		}
+		stream.Heartbeat(time.Millisecond)
+	})
+	router.GET("/", func(ctx *Context) {
+		time.Sleep(5 * time.Millisecond)
+	})
+
+	srv := New("")
+	srv.init(router.Handler())
+	srv.Server.Handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(MethodGet, "/events", nil))
+	if err := stream.Send(Event{Data: "foo"}); err != errEventStreamClosed {
+		t.Errorf("expected error %q, got %v", errEventStreamClosed, err)
+	}
+
+	// the Context may be reused, the heartbeats must not be written
+	// into the other responses.
+	w := httptest.NewRecorder()
+	srv.Server.Handler.ServeHTTP(w, httptest.NewRequest(MethodGet, "/", nil))
+	if w.Body.Len() != 0 {
+		t.Errorf("unexpected body %q", w.Body.String())
+	}
+}
@@ -8,19 +8,41 @@ import (
 	"errors"
 	"fmt"
 	"html/template"
+	"io/fs"
+	"os"
 	"path"
 	"path/filepath"
+	"strings"
+	"sync"
+	"time"
 )
 
 // NewTemplates returns a Templates instance with the given path
 // and default options.
+//
+// The following functions are available to every template, they
+// can be overridden by FuncMap:
+//
+//	url        - generates URL of named route, such as {{url "user" .Name}}
+//	asset      - prefixes the path with AssetPrefix, such as {{asset "css/main.css"}}
+//	date       - formats time, such as {{date .Created "2006-01-02"}}
+//	number     - formats number with thousands separators, such as {{number 1234.5 2}}
+//	upper, lower, title, trim, truncate, replace, contains,
+//	hasPrefix, hasSuffix, split, join - the string helpers
+//	json       - encodes value as JSON
+//	safeHTML, safeURL, safeJS - marks string as trusted content
+//	dict       - builds a map from key-value pairs, such as {{dict "name" .Name}}
+//	list       - builds a slice, such as {{list 1 2 3}}
+//	csrfToken  - returns the CSRF token of the context that is rendering,
+//	             such as {{csrfToken}}, see Context.Render
 func NewTemplates(path string) *Templates {
 	return &Templates{
-		Path:      path,
-		Suffix:    ".html",
-		Delims:    []string{"{{", "}}"},
-		LayoutDir: "layouts",
-		layouts:   make(map[string]*template.Template),
+		Path:       path,
+		Suffix:     ".html",
+		Delims:     []string{"{{", "}}"},
+		LayoutDir:  "layouts",
+		PartialDir: "partials",
+		layouts:    make(map[string]*layout),
 	}
 }
 
@@ -31,42 +53,273 @@ type Templates struct {
 	Delims    []string
 	FuncMap   template.FuncMap
 	LayoutDir string
-	layouts   map[string]*template.Template
+
+	// PartialDir is the directory of partials, which are parsed into
+	// every layout, so that they are available to every template,
+	// such as {{template "nav.html" .}}, empty means no partials.
+	PartialDir string
+
+	// Extends maps layout's name to its parent layout's name, the
+	// parent's files are parsed before the layout's files, so that
+	// the layout can override the blocks of its ancestors:
+	//
+	//	base: {{block "content" .}}{{end}}
+	//	admin: {{define "content"}}<aside></aside>{{block "main" .}}{{end}}{{end}}
+	//	page: {{define "main"}}dashboard{{end}}
+	Extends map[string]string
+
+	// AssetPrefix is the prefix of URL generated by the "asset"
+	// function, such as "/static" or "https://cdn.example.com".
+	AssetPrefix string
+
+	// FS is the file system that the templates are loaded from,
+	// such as an embed.FS, the OS file system is used if nil.
+	// The Path and filenames are slash-separated paths of FS.
+	FS fs.FS
+
+	// Development enables development mode, the modification times
+	// of layout's files are checked via stat on every access, rather
+	// than watching the files, and the layout would be reparsed if
+	// any of them has been modified. The cache built by Compile is
+	// disabled as well.
+	Development bool
+
+	// router is used by the "url" function.
+	router *Router
+
+	mu      sync.RWMutex
+	layouts map[string]*layout
+	cache   map[string]*template.Template
+}
+
+type layout struct {
+	tmpl *template.Template
+
+	// own contains the files that were given by SetLayout, filenames
+	// contains the files of ancestors and partials as well.
+	own       []string
+	filenames []string
+	modTime   time.Time
 }
 
 var errNoTemplateSpecified = errors.New("no template file specified")
 
+// Funcs adds the elements of the argument map to the Templates's
+// function map, it must be called before the templates are parsed.
+// It is legal to overwrite elements of the map.
+func (ts *Templates) Funcs(funcMap template.FuncMap) *Templates {
+	if ts.FuncMap == nil {
+		ts.FuncMap = make(template.FuncMap, len(funcMap))
+	}
+
+	for name, fn := range funcMap {
+		ts.FuncMap[name] = fn
+	}
+
+	return ts
+}
+
 // SetLayout set layout.
 func (ts *Templates) SetLayout(filenames ...string) error {
+	if len(filenames) == 0 {
+		return errNoTemplateSpecified
+	}
+
 	for i, filename := range filenames {
 		filenames[i] = path.Join(ts.Path, ts.LayoutDir, filename+ts.Suffix)
 	}
 
-	layout, err := ts.New(filenames...)
+	name := filepath.Base(filenames[0])
+	l, err := ts.parseLayout(name, filenames)
 	if err != nil {
 		return err
 	}
 
-	name := filepath.Base(filenames[0])
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
 	if _, ok := ts.layouts[name]; ok {
 		return fmt.Errorf("the layout named %q already exists", name)
 	}
 
-	ts.layouts[name] = layout
+	ts.layouts[name] = l
 
 	return nil
 }
 
-// Layout get layout by the given name.
+func (ts *Templates) parseLayout(name string, own []string) (*layout, error) {
+	filenames, err := ts.layoutFilenames(name, own)
+	if err != nil {
+		return nil, err
+	}
+
+	modTime, err := ts.latestModTime(filenames)
+	if err != nil {
+		return nil, err
+	}
+
+	tmpl, err := ts.New(filenames...)
+	if err != nil {
+		return nil, err
+	}
+
+	return &layout{tmpl: tmpl, own: own, filenames: filenames, modTime: modTime}, nil
+}
+
+// layoutFilenames returns the files of the layout in order of parsing:
+// the files of ancestors from the root, the layout's own files and
+// the partials.
+func (ts *Templates) layoutFilenames(name string, own []string) ([]string, error) {
+	var ancestors [][]string
+	visited := map[string]bool{name: true}
+	for parent := ts.Extends[strings.TrimSuffix(name, ts.Suffix)]; parent != ""; parent = ts.Extends[parent] {
+		key := parent + ts.Suffix
+		if visited[key] {
+			return nil, fmt.Errorf("the layout named %q extends itself", parent)
+		}
+		visited[key] = true
+
+		// the parent's default file is used if it was not set.
+		files := []string{path.Join(ts.Path, ts.LayoutDir, key)}
+		ts.mu.RLock()
+		if l, ok := ts.layouts[key]; ok {
+			files = l.own
+		}
+		ts.mu.RUnlock()
+
+		ancestors = append(ancestors, files)
+	}
+
+	var filenames []string
+	for i := len(ancestors) - 1; i >= 0; i-- {
+		filenames = append(filenames, ancestors[i]...)
+	}
+	filenames = append(filenames, own...)
+
+	if ts.PartialDir != "" {
+		partials, err := ts.templateNames(ts.PartialDir)
+		if err != nil {
+			return nil, err
+		}
+		filenames = append(filenames, ts.Filenames(partials...)...)
+	}
+
+	return filenames, nil
+}
+
+// templateNames returns the names of template files under the given
+// directory, the names are relative to Path without suffix, the skipped
+// directories are relative to Path as well.
+func (ts *Templates) templateNames(dir string, skips ...string) ([]string, error) {
+	fsys, root := ts.FS, ts.Path
+	if fsys == nil {
+		if root == "" {
+			root = "."
+		}
+		fsys, root = os.DirFS(root), "."
+	} else if root == "" {
+		root = "."
+	}
+
+	skipDirs := make(map[string]bool, len(skips))
+	for _, skip := range skips {
+		if skip != "" {
+			skipDirs[path.Join(root, skip)] = true
+		}
+	}
+
+	var names []string
+	err := fs.WalkDir(fsys, path.Join(root, dir), func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if skipDirs[name] {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if !strings.HasSuffix(name, ts.Suffix) {
+			return nil
+		}
+
+		name = strings.TrimSuffix(name, ts.Suffix)
+		if root != "." {
+			name = strings.TrimPrefix(name, root+"/")
+		}
+		names = append(names, name)
+		return nil
+	})
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+
+	return names, nil
+}
 
+// latestModTime returns the latest modification time of the files.
+func (ts *Templates) latestModTime(filenames []string) (modTime time.Time, err error) {
+	for _, filename := range filenames {
+		var info fs.FileInfo
+		if ts.FS != nil {
+			info, err = fs.Stat(ts.FS, filename)
+		} else {
+			info, err = os.Stat(filename)
+		}
+		if err != nil {
+			return modTime, err
+		}
+		if info.ModTime().After(modTime) {
+			modTime = info.ModTime()
+		}
+	}
+
+	return modTime, nil
+}
+
+// Layout get layout by the given name.
+//
+// In development mode, the files of layout are checked via stat on
+// every call, and the layout would be reparsed if any of them has
+// been modified.
+//
 // if the layout does not exists, returns
 // non-nil error.
 func (ts *Templates) Layout(name string) (*template.Template, error) {
-	if layout, ok := ts.layouts[name+ts.Suffix]; ok {
-		return layout, nil
+	name += ts.Suffix
+
+	ts.mu.RLock()
+	l, ok := ts.layouts[name]
+	ts.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("no layout named %q", strings.TrimSuffix(name, ts.Suffix))
+	}
+
+	if !ts.Development {
+		return l.tmpl, nil
 	}
 
-	return nil, fmt.Errorf("no layout named %q", name)
+	filenames, err := ts.layoutFilenames(name, l.own)
+	if err != nil {
+		return nil, err
+	}
+	modTime, err := ts.latestModTime(filenames)
+	if err != nil {
+		return nil, err
+	}
+	if !modTime.After(l.modTime) && strings.Join(filenames, "\x00") == strings.Join(l.filenames, "\x00") {
+		return l.tmpl, nil
+	}
+
+	if l, err = ts.parseLayout(name, l.own); err != nil {
+		return nil, err
+	}
+
+	ts.mu.Lock()
+	ts.layouts[name] = l
+	ts.mu.Unlock()
+
+	return l.tmpl, nil
 }
 
 // Filenames converts relative paths to absolute paths.
@@ -79,7 +332,8 @@ func (ts *Templates) Filenames(filenames ...string) []string {
 }
 
 // New returns a template.Template instance with
-// the Templates's option and template.ParseFiles.
+// the Templates's option and template.ParseFiles,
+// or template.ParseFS if FS is specified.
 //
 // Note: filenames should be absolute paths,
 // either uses Templates.Filenames or specifies manually.
@@ -89,18 +343,43 @@ func (ts *Templates) New(filenames ...string) (*template.Template, error) {
 	}
 
 	name := filepath.Base(filenames[0])
-	return template.New(name).
+	tmpl := template.New(name).
 		Delims(ts.Delims[0], ts.Delims[1]).
-		Funcs(ts.FuncMap).
-		ParseFiles(filenames...)
+		Funcs(ts.defaultFuncs()).
+		Funcs(ts.FuncMap)
+
+	return ts.parseFiles(tmpl, filenames...)
+}
+
+func (ts *Templates) parseFiles(tmpl *template.Template, filenames ...string) (*template.Template, error) {
+	if ts.FS != nil {
+		return tmpl.ParseFS(ts.FS, filenames...)
+	}
+
+	return tmpl.ParseFiles(filenames...)
 }
 
 // Render uses layout to render template.
+//
+// The templates compiled by Compile are cloned rather than parsed
+// unless in development mode, the returned template is owned by the
+// caller, modifying it does not affect the cache.
 func (ts *Templates) Render(layoutName string, filenames ...string) (*template.Template, error) {
 	if len(filenames) == 0 {
 		return nil, errNoTemplateSpecified
 	}
 
+	if !ts.Development {
+		ts.mu.RLock()
+		tmpl, ok := ts.cache[cacheKey(layoutName, filenames)]
+		ts.mu.RUnlock()
+		if ok {
+			// the cached template is never executed, so that it
+			// can always be cloned.
+			return tmpl.Clone()
+		}
+	}
+
 	layout, err := ts.Layout(layoutName)
 	if err != nil {
 		return nil, err
@@ -110,5 +389,47 @@ func (ts *Templates) Render(layoutName string, filenames ...string) (*template.T
 		return nil, err
 	}
 
-	return layout.ParseFiles(ts.Filenames(filenames...)...)
+	return ts.parseFiles(layout, ts.Filenames(filenames...)...)
+}
+
+func cacheKey(layoutName string, filenames []string) string {
+	return layoutName + "\x00" + strings.Join(filenames, "\x00")
+}
+
+// Compile parses every template file under Path, except the files in
+// LayoutDir and PartialDir, with each layout, and caches them so that Render does not
+// read files any more.
+func (ts *Templates) Compile() error {
+	names, err := ts.templateNames("", ts.LayoutDir, ts.PartialDir)
+	if err != nil {
+		return err
+	}
+
+	ts.mu.RLock()
+	layoutNames := make([]string, 0, len(ts.layouts))
+	layoutFiles := make(map[string]string, len(ts.layouts))
+	for name, l := range ts.layouts {
+		layoutName := strings.TrimSuffix(name, ts.Suffix)
+		layoutNames = append(layoutNames, layoutName)
+		layoutFiles[layoutName] = strings.Join(l.own, ", ")
+	}
+	ts.mu.RUnlock()
+
+	cache := make(map[string]*template.Template, len(layoutNames)*len(names))
+	for _, layoutName := range layoutNames {
+		for _, name := range names {
+			tmpl, err := ts.Render(layoutName, name)
+			if err != nil {
+				return fmt.Errorf("failed to compile template %s with layout %s: %w",
+					ts.Filenames(name)[0], layoutFiles[layoutName], err)
+			}
+			cache[cacheKey(layoutName, []string{name})] = tmpl
+		}
+	}
+
+	ts.mu.Lock()
+	ts.cache = cache
+	ts.mu.Unlock()
+
+	return nil
 }
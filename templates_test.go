@@ -7,10 +7,15 @@ package gem
 import (
 	"bytes"
 	"fmt"
+	"html/template"
 	"io/ioutil"
 	"os"
 	"path"
+	"strconv"
+	"strings"
 	"testing"
+	"testing/fstest"
+	"time"
 )
 
 var (
@@ -43,6 +48,11 @@ func init() {
 			[]byte(`{{define "body"}}hello wolrd{{end}}`),
 			os.ModePerm,
 		},
+		{
+			path.Join(testPath, "broken.html"),
+			[]byte(`{{define "body"}}half-written{{index . 5}}{{end}}`),
+			os.ModePerm,
+		},
 	}
 
 	for _, file := range files {
@@ -133,3 +143,293 @@ func TestTemplates_New(t *testing.T) {
 		t.Error("wrong hint for rendering empty template")
 	}
 }
+
+func TestTemplates_Funcs(t *testing.T) {
+	ts := NewTemplates(testPath)
+	ts.Funcs(template.FuncMap{"foo": func() string { return "foo" }})
+	ts.Funcs(template.FuncMap{"bar": func() string { return "bar" }})
+
+	if len(ts.FuncMap) != 2 || ts.FuncMap["foo"] == nil || ts.FuncMap["bar"] == nil {
+		t.Errorf("failed to add funcs, got %v", ts.FuncMap)
+	}
+}
+
+func newTestTemplatesDir(t *testing.T, files map[string]string) string {
+	root := path.Join(os.TempDir(), "templates-"+strconv.Itoa(time.Now().Nanosecond()))
+	for name, data := range files {
+		filename := path.Join(root, name)
+		if err := os.MkdirAll(path.Dir(filename), os.ModePerm); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filename, []byte(data), os.ModePerm); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
+
+func executeTemplate(t *testing.T, ts *Templates, layout, name string) string {
+	tmpl, err := ts.Render(layout, name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf := &bytes.Buffer{}
+	if err = tmpl.Execute(buf, nil); err != nil {
+		t.Fatal(err)
+	}
+	return buf.String()
+}
+
+func TestTemplates_Development(t *testing.T) {
+	root := newTestTemplatesDir(t, map[string]string{
+		"layouts/main.html": `<main>{{block "body" .}}{{end}}</main>`,
+		"index.html":        `{{define "body"}}index{{end}}`,
+	})
+	defer os.RemoveAll(root)
+
+	ts := NewTemplates(root)
+	ts.Development = true
+	if err := ts.SetLayout("main"); err != nil {
+		t.Fatal(err)
+	}
+	if got := executeTemplate(t, ts, "main", "index"); got != "<main>index</main>" {
+		t.Errorf("expected html %q, got %q", "<main>index</main>", got)
+	}
+
+	// modifies the layout and page.
+	layoutName := path.Join(root, "layouts", "main.html")
+	if err := ioutil.WriteFile(layoutName, []byte(`<div>{{block "body" .}}{{end}}</div>`), os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	future := time.Now().Add(time.Second)
+	os.Chtimes(layoutName, future, future)
+	if err := ioutil.WriteFile(path.Join(root, "index.html"), []byte(`{{define "body"}}new index{{end}}`), os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	if got := executeTemplate(t, ts, "main", "index"); got != "<div>new index</div>" {
+		t.Errorf("expected html %q, got %q", "<div>new index</div>", got)
+	}
+
+	// the broken layout.
+	os.Remove(layoutName)
+	if _, err := ts.Layout("main"); err == nil {
+		t.Error("expected non-nil error, got nil")
+	}
+}
+
+func TestTemplates_Compile(t *testing.T) {
+	root := newTestTemplatesDir(t, map[string]string{
+		"layouts/main.html":  `<main>{{block "body" .}}{{end}}</main>`,
+		"layouts/admin.html": `<admin>{{block "body" .}}{{end}}</admin>`,
+		"index.html":         `{{define "body"}}index{{end}}`,
+		"users/list.html":    `{{define "body"}}users{{end}}`,
+		"README.md":          `ignored`,
+	})
+	defer os.RemoveAll(root)
+
+	ts := NewTemplates(root)
+	if err := ts.SetLayout("main"); err != nil {
+		t.Fatal(err)
+	}
+	if err := ts.SetLayout("admin"); err != nil {
+		t.Fatal(err)
+	}
+	if err := ts.Compile(); err != nil {
+		t.Fatal(err)
+	}
+	if len(ts.cache) != 4 {
+		t.Errorf("expected %d compiled templates, got %d", 4, len(ts.cache))
+	}
+
+	// no disk I/O any more.
+	os.RemoveAll(root)
+	tests := []struct {
+		layout, name, want string
+	}{
+		{"main", "index", "<main>index</main>"},
+		{"main", "users/list", "<main>users</main>"},
+		{"admin", "index", "<admin>index</admin>"},
+		{"admin", "users/list", "<admin>users</admin>"},
+	}
+	for _, test := range tests {
+		if got := executeTemplate(t, ts, test.layout, test.name); got != test.want {
+			t.Errorf("expected html %q, got %q", test.want, got)
+		}
+	}
+
+	// the missing directory is ignored.
+	if err := NewTemplates(root).Compile(); err != nil {
+		t.Errorf("expected nil error, got %s", err)
+	}
+
+	root = newTestTemplatesDir(t, map[string]string{
+		"layouts/main.html": `{{block "body" .}}{{end}}`,
+		"broken.html":       `{{define "body"}}{{end`,
+	})
+	defer os.RemoveAll(root)
+	ts = NewTemplates(root)
+	if err := ts.SetLayout("main"); err != nil {
+		t.Fatal(err)
+	}
+	// the error names the template and layout.
+	err := ts.Compile()
+	if err == nil || !strings.Contains(err.Error(), path.Join(root, "broken.html")) ||
+		!strings.Contains(err.Error(), path.Join(root, "layouts", "main.html")) {
+		t.Errorf("expected parse error naming the files, got %v", err)
+	}
+}
+
+func TestTemplates_RenderCompiledClone(t *testing.T) {
+	ts := NewTemplates("")
+	ts.FS = fstest.MapFS{
+		"layouts/main.html": {Data: []byte(`<main>{{block "body" .}}{{end}}</main>`)},
+		"index.html":        {Data: []byte(`{{define "body"}}index{{end}}`)},
+	}
+	if err := ts.SetLayout("main"); err != nil {
+		t.Fatal(err)
+	}
+	if err := ts.Compile(); err != nil {
+		t.Fatal(err)
+	}
+
+	tmpl, err := ts.Render("main", "index")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tmpl == ts.cache[cacheKey("main", []string{"index"})] {
+		t.Fatal("expected a clone of the compiled template")
+	}
+	// modifying and executing the returned template does not affect the cache.
+	if _, err = tmpl.New("body").Parse("modified"); err != nil {
+		t.Fatal(err)
+	}
+	if err = tmpl.Execute(ioutil.Discard, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if got := executeTemplate(t, ts, "main", "index"); got != "<main>index</main>" {
+			t.Errorf("expected html %q, got %q", "<main>index</main>", got)
+		}
+	}
+}
+
+func TestTemplates_FS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"templates/layouts/main.html": {Data: []byte(`<main>{{block "body" .}}{{end}}</main>`)},
+		"templates/index.html":        {Data: []byte(`{{define "body"}}index{{end}}`)},
+		"templates/users/list.html":   {Data: []byte(`{{define "body"}}users{{end}}`)},
+	}
+
+	for _, root := range []string{"templates", ""} {
+		files := fsys
+		if root == "" {
+			files = fstest.MapFS{}
+			for name, file := range fsys {
+				files[strings.TrimPrefix(name, "templates/")] = file
+			}
+		}
+
+		ts := NewTemplates(root)
+		ts.FS = files
+		ts.Development = true
+		if err := ts.SetLayout("main"); err != nil {
+			t.Fatal(err)
+		}
+		if got := executeTemplate(t, ts, "main", "users/list"); got != "<main>users</main>" {
+			t.Errorf("expected html %q, got %q", "<main>users</main>", got)
+		}
+
+		ts.Development = false
+		if err := ts.Compile(); err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := ts.cache[cacheKey("main", []string{"users/list"})]; !ok || len(ts.cache) != 2 {
+			t.Errorf("failed to compile templates from fs.FS, got %v", ts.cache)
+		}
+	}
+}
+
+func TestTemplates_Extends(t *testing.T) {
+	fsys := fstest.MapFS{
+		"layouts/base.html":    {Data: []byte(`<html>{{template "nav.html" .}}{{block "content" .}}base{{end}}</html>`)},
+		"layouts/section.html": {Data: []byte(`{{define "content"}}<section>{{block "main" .}}{{end}}</section>{{end}}`)},
+		"layouts/admin.html":   {Data: []byte(`{{define "main"}}<admin>{{block "page" .}}{{end}}</admin>{{end}}`)},
+		"partials/nav.html":    {Data: []byte(`<nav>{{template "title" .}}</nav>`)},
+		"partials/title.html":  {Data: []byte(`{{define "title"}}{{.}}{{end}}`)},
+		"index.html":           {Data: []byte(`{{define "main"}}index{{end}}`)},
+		"dashboard.html":       {Data: []byte(`{{define "page"}}dashboard{{end}}`)},
+	}
+
+	ts := NewTemplates("")
+	ts.FS = fsys
+	ts.Extends = map[string]string{"section": "base", "admin": "section"}
+	for _, name := range []string{"admin", "base", "section"} {
+		if err := ts.SetLayout(name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := ts.Compile(); err != nil {
+		t.Fatal(err)
+	}
+	// the layouts and partials are not compiled as pages.
+	if len(ts.cache) != 6 {
+		t.Errorf("expected %d compiled templates, got %d", 6, len(ts.cache))
+	}
+
+	tests := []struct {
+		layout, name, want string
+	}{
+		{"base", "index", "<html><nav>foo</nav>base</html>"},
+		{"section", "index", "<html><nav>foo</nav><section>index</section></html>"},
+		{"admin", "dashboard", "<html><nav>foo</nav><section><admin>dashboard</admin></section></html>"},
+	}
+	for _, test := range tests {
+		tmpl, err := ts.Render(test.layout, test.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		buf := &bytes.Buffer{}
+		if err = tmpl.Execute(buf, "foo"); err != nil {
+			t.Fatal(err)
+		}
+		if buf.String() != test.want {
+			t.Errorf("expected html %q, got %q", test.want, buf.String())
+		}
+	}
+
+	ts = NewTemplates("")
+	ts.FS = fsys
+	ts.Extends = map[string]string{"base": "admin", "admin": "base"}
+	if err := ts.SetLayout("base"); err == nil || err.Error() != `the layout named "base" extends itself` {
+		t.Errorf("expected circular extending error, got %v", err)
+	}
+}
+
+func TestTemplates_DevelopmentPartials(t *testing.T) {
+	root := newTestTemplatesDir(t, map[string]string{
+		"layouts/main.html": `<main>{{block "nav" .}}{{end}}</main>`,
+		"index.html":        `{{define "body"}}{{end}}`,
+	})
+	defer os.RemoveAll(root)
+
+	ts := NewTemplates(root)
+	ts.Development = true
+	if err := ts.SetLayout("main"); err != nil {
+		t.Fatal(err)
+	}
+	if got := executeTemplate(t, ts, "main", "index"); got != "<main></main>" {
+		t.Errorf("expected html %q, got %q", "<main></main>", got)
+	}
+
+	// the new partial is available without restarting.
+	if err := os.MkdirAll(path.Join(root, "partials"), os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(path.Join(root, "partials", "nav.html"), []byte(`{{define "nav"}}<nav></nav>{{end}}`), os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	if got := executeTemplate(t, ts, "main", "index"); got != "<main><nav></nav></main>" {
+		t.Errorf("expected html %q, got %q", "<main><nav></nav></main>", got)
+	}
+}
@@ -24,6 +24,11 @@ func countParams(path string) uint8 {
 			continue
 		}
 		n++
+
+		// skip the wildcard, its constraint may contain ':' and '*'
+		if _, _, end := scanWildcard(path, i); end > i {
+			i = end - 1
+		}
 	}
 	if n >= 255 {
 		return 255
@@ -49,6 +54,12 @@ type node struct {
 	children  []*node
 	handle    Handler
 	priority  uint32
+
+	// key is the name of param node, match reports whether the
+	// value satisfies the constraint of param node, it is nil if
+	// the param node has no constraint.
+	key   string
+	match func(string) bool
 }
 
 // increments priority of the given child and reorders if necessary
@@ -219,16 +230,20 @@ func (n *node) insertChild(numParams uint8, path, fullPath string, handle Handle
 		}
 
 		// find wildcard end (either '/' or path end)
-		end := i + 1
-		for end < max && path[end] != '/' {
-			switch path[end] {
-			// the wildcard name must not contain ':' and '*'
-			case ':', '*':
-				panic("only one wildcard per path segment is allowed, has: '" +
-					path[i:] + "' in path '" + fullPath + "'")
-			default:
-				end++
-			}
+		name, constraint, end := scanWildcard(path, i)
+		if end < 0 {
+			panic("unterminated constraint of wildcard '" + path[i:] +
+				"' in path '" + fullPath + "'")
+		}
+		// the wildcard name must not contain ':' and '*'
+		if strings.ContainsAny(name, ":*") {
+			panic("only one wildcard per path segment is allowed, has: '" +
+				path[i:] + "' in path '" + fullPath + "'")
+		}
+		// the constraint must be the end of wildcard
+		if end < max && path[end] != '/' {
+			panic("constraint must be followed by '/' or path end, has: '" +
+				path[i:] + "' in path '" + fullPath + "'")
 		}
 
 		// check if this Node existing children which would be
@@ -239,7 +254,7 @@ func (n *node) insertChild(numParams uint8, path, fullPath string, handle Handle
 		}
 
 		// check if the wildcard has a name
-		if end-i < 2 {
+		if len(name) == 0 {
 			panic("wildcards must be named with a non-empty name in path '" + fullPath + "'")
 		}
 
@@ -254,6 +269,15 @@ func (n *node) insertChild(numParams uint8, path, fullPath string, handle Handle
 			child := &node{
 				nType:     param,
 				maxParams: numParams,
+				key:       name,
+			}
+			if constraint != "" {
+				match, err := compileConstraint(constraint)
+				if err != nil {
+					panic("invalid constraint of wildcard '" + path[i:end] +
+						"' in path '" + fullPath + "': " + err.Error())
+				}
+				child.match = match
 			}
 			n.children = []*node{child}
 			n.wildChild = true
@@ -275,6 +299,10 @@ func (n *node) insertChild(numParams uint8, path, fullPath string, handle Handle
 				n = child
 			}
 
+			// continue after the wildcard, since the constraint
+			// may contain ':' and '*'
+			i = end - 1
+
 		} else {
 			// catchAll
 			if end != max || numParams > 1 {
@@ -324,11 +352,15 @@ func (n *node) insertChild(numParams uint8, path, fullPath string, handle Handle
 }
 
 // Returns the handle registered with the given path (key). The values of
-// wildcards are saved to a map.
+// wildcards are saved to the context's parameters.
 // If no handle can be found, a TSR (trailing slash redirect) recommendation is
 // made if a handle exists with an extra (without the) trailing slash for the
 // given path.
 func (n *node) getValue(path string, ctx *Context) (handle Handler, tsr bool) {
+	// discard the parameters of previous lookup, except the ones
+	// inherited from the parent router, see Router.ServeHTTP.
+	ctx.params = ctx.params[:ctx.inheritedParams]
+
 walk: // outer loop for walking the tree
 	for {
 		if len(path) > len(n.path) {
@@ -364,8 +396,13 @@ walk: // outer loop for walking the tree
 						end++
 					}
 
+					// check param constraint
+					if n.match != nil && !n.match(path[:end]) {
+						return
+					}
+
 					// save param value
-					ctx.SetUserValue(n.path[1:], path[:end])
+					ctx.setParam(n.key, path[:end])
 
 					// we need to go deeper!
 					if end < len(path) {
@@ -393,7 +430,7 @@ walk: // outer loop for walking the tree
 
 				case catchAll:
 					// save param value
-					ctx.SetUserValue(n.path[2:], path)
+					ctx.setParam(n.path[2:], path)
 
 					handle = n.handle
 					return
@@ -564,6 +601,11 @@ walk: // outer loop for walking the tree
 					k++
 				}
 
+				// check param constraint
+				if n.match != nil && !n.match(path[:k]) {
+					return ciPath, false
+				}
+
 				// add param value to case insensitive path
 				ciPath = append(ciPath, path[:k]...)
 
@@ -56,8 +56,8 @@ func checkRequests(t *testing.T, tree *node, requests testRequests) {
 		}
 
 		for _, v := range request.userValue {
-			if !reflect.DeepEqual(ctx.UserValue(v.key), v.value) {
-				t.Errorf("Params mismatch for route '%s', %v, %v", request.path, ctx.UserValue(v.key), v.value)
+			if !reflect.DeepEqual(ctx.Param(v.key), v.value) {
+				t.Errorf("Params mismatch for route '%s', %v, %v", request.path, ctx.Param(v.key), v.value)
 			}
 		}
 	}
@@ -112,6 +112,9 @@ func TestCountParams(t *testing.T) {
 	if countParams(strings.Repeat("/:param", 256)) != 255 {
 		t.Fail()
 	}
+	if countParams("/path/:param1<[a-z:]*>/static/*catch-all") != 2 {
+		t.Fail()
+	}
 }
 
 func TestTreeAddAndGet(t *testing.T) {
@@ -366,6 +369,84 @@ func TestTreeDoubleWildcard(t *testing.T) {
 	}
 }
 
+func TestTreeConstraint(t *testing.T) {
+	tree := &node{}
+
+	routes := [...]string{
+		"/users/:id<int>",
+		"/users/:id<int>/posts/:uuid<uuid>",
+		"/tags/:tag<alpha>",
+		"/codes/:code<alnum>/:n<uint>",
+		"/posts/:slug<[a-z0-9-]+>",
+		`/archives/:date<\d{4}-\d{2}-\d{2}>/`,
+		"/files/:dir<[a-z]*>/*filepath",
+		"/years/:year<(?P<y>\\d{4})>",
+	}
+	for _, route := range routes {
+		tree.addRoute(route, fakeHandler(route))
+	}
+
+	//printChildren(tree, "")
+
+	checkRequests(t, tree, testRequests{
+		{"/users/2016", false, "/users/:id<int>", userValue{userData{"id", "2016"}}},
+		{"/users/-1", false, "/users/:id<int>", userValue{userData{"id", "-1"}}},
+		{"/users/foo", true, "", nil},
+		{"/users/2016/posts/6ba7b810-9dad-11d1-80b4-00c04fd430c8", false, "/users/:id<int>/posts/:uuid<uuid>", userValue{userData{"id", "2016"}, userData{"uuid", "6ba7b810-9dad-11d1-80b4-00c04fd430c8"}}},
+		{"/users/2016/posts/foo", true, "", nil},
+		{"/tags/golang", false, "/tags/:tag<alpha>", userValue{userData{"tag", "golang"}}},
+		{"/tags/go1", true, "", nil},
+		{"/codes/go1/8", false, "/codes/:code<alnum>/:n<uint>", userValue{userData{"code", "go1"}, userData{"n", "8"}}},
+		{"/codes/go-1/8", true, "", nil},
+		{"/codes/go1/-8", true, "", nil},
+		{"/posts/hello-world-2016", false, "/posts/:slug<[a-z0-9-]+>", userValue{userData{"slug", "hello-world-2016"}}},
+		{"/posts/Hello", true, "", nil},
+		{"/archives/2016-12-31/", false, `/archives/:date<\d{4}-\d{2}-\d{2}>/`, userValue{userData{"date", "2016-12-31"}}},
+		{"/archives/2016-1-1/", true, "", nil},
+		{"/files/js/app.js", false, "/files/:dir<[a-z]*>/*filepath", userValue{userData{"dir", "js"}, userData{"filepath", "/app.js"}}},
+		{"/files/JS/app.js", true, "", nil},
+		{"/years/2016", false, "/years/:year<(?P<y>\\d{4})>", userValue{userData{"year", "2016"}}},
+		{"/years/16", true, "", nil},
+	})
+
+	checkPriorities(t, tree)
+	checkMaxParams(t, tree)
+
+	// case insensitive lookup should respect the constraints.
+	if _, found := tree.findCaseInsensitivePath("/TAGS/golang", false); !found {
+		t.Error("Route '/TAGS/golang' not found!")
+	}
+	if _, found := tree.findCaseInsensitivePath("/TAGS/go1", false); found {
+		t.Error("Route '/TAGS/go1' should not be found!")
+	}
+}
+
+func TestTreeInvalidConstraint(t *testing.T) {
+	routes := [...]string{
+		"/users/:id<int",
+		"/users/:id<int>x",
+		"/users/:<int>",
+		"/users/:id<[a-z>",
+		"/users/:id<int>:name",
+	}
+	for _, route := range routes {
+		tree := &node{}
+		recv := catchPanic(func() {
+			tree.addRoute(route, nil)
+		})
+		if recv == nil {
+			t.Errorf("no panic while inserting route with invalid constraint '%s'", route)
+		}
+	}
+
+	testRoutes(t, []testRoute{
+		{"/users/:id<int>", false},
+		{"/users/:id<int>/posts", false},
+		{"/users/:id<uint>", true},
+		{"/users/:name", true},
+	})
+}
+
 /*func TestTreeDuplicateWildcard(t *testing.T) {
 	tree := &node{}
 
@@ -5,6 +5,8 @@
 package gem
 
 import (
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"strconv"
 )
@@ -19,6 +21,8 @@ func String(v interface{}) (string, error) {
 		return value, nil
 	case int:
 		return strconv.Itoa(value), nil
+	case int64:
+		return strconv.FormatInt(value, 10), nil
 	case []byte:
 		return string(value), nil
 	}
@@ -40,3 +44,57 @@ func Int(v interface{}) (int, error) {
 
 	return 0, fmt.Errorf("unsupport to convert type %T to int", v)
 }
+
+// UUID is a universally unique identifier defined in RFC 4122.
+type UUID [16]byte
+
+var errInvalidUUID = errors.New("invalid UUID format")
+
+// ParseUUID parses the canonical representation of UUID,
+// such as "6ba7b810-9dad-11d1-80b4-00c04fd430c8".
+//
+// Both uppercase and lowercase hexadecimal digits are accepted.
+func ParseUUID(s string) (uuid UUID, err error) {
+	if len(s) != 36 || s[8] != '-' || s[13] != '-' || s[18] != '-' || s[23] != '-' {
+		return uuid, errInvalidUUID
+	}
+
+	src := []byte(s)
+	groups := [...][2]int{{0, 8}, {9, 13}, {14, 18}, {19, 23}, {24, 36}}
+	n := 0
+	for _, g := range groups {
+		if _, err = hex.Decode(uuid[n:], src[g[0]:g[1]]); err != nil {
+			return UUID{}, errInvalidUUID
+		}
+		n += (g[1] - g[0]) / 2
+	}
+
+	return uuid, nil
+}
+
+// String returns the canonical representation of uuid.
+func (uuid UUID) String() string {
+	buf := make([]byte, 36)
+	hex.Encode(buf[0:8], uuid[0:4])
+	buf[8] = '-'
+	hex.Encode(buf[9:13], uuid[4:6])
+	buf[13] = '-'
+	hex.Encode(buf[14:18], uuid[6:8])
+	buf[18] = '-'
+	hex.Encode(buf[19:23], uuid[8:10])
+	buf[23] = '-'
+	hex.Encode(buf[24:], uuid[10:])
+
+	return string(buf)
+}
+
+// MarshalText implements the encoding.TextMarshaler interface.
+func (uuid UUID) MarshalText() ([]byte, error) {
+	return []byte(uuid.String()), nil
+}
+
+// UnmarshalText implements the encoding.TextUnmarshaler interface.
+func (uuid *UUID) UnmarshalText(text []byte) (err error) {
+	*uuid, err = ParseUUID(string(text))
+	return
+}
@@ -20,6 +20,7 @@ var (
 	testStrings = []testString{
 		testString{"foo", "foo", nil},
 		testString{1, "1", nil},
+		testString{int64(20161231), "20161231", nil},
 		testString{[]byte("bar"), "bar", nil},
 		testString{false, "", fmt.Errorf("unsupport to convert type %T to string", false)},
 	}
@@ -71,3 +72,34 @@ func TestInt(t *testing.T) {
 		}
 	}
 }
+
+func TestParseUUID(t *testing.T) {
+	s := "6ba7b810-9dad-11d1-80b4-00c04fd430c8"
+	uuid, err := ParseUUID(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := UUID{0x6b, 0xa7, 0xb8, 0x10, 0x9d, 0xad, 0x11, 0xd1, 0x80, 0xb4, 0x00, 0xc0, 0x4f, 0xd4, 0x30, 0xc8}
+	if uuid != want {
+		t.Errorf("expected %v, got %v", want, uuid)
+	}
+	if uuid.String() != s {
+		t.Errorf("expected %q, got %q", s, uuid.String())
+	}
+
+	if uuid, err = ParseUUID("6BA7B810-9DAD-11D1-80B4-00C04FD430C8"); err != nil || uuid != want {
+		t.Errorf("failed to parse uppercase UUID: %v", err)
+	}
+
+	invalids := []string{
+		"",
+		"6ba7b8109dad11d180b400c04fd430c8",
+		"6ba7b810-9dad-11d1-80b4_00c04fd430c8",
+		"6ba7b810-9dad-11d1-80b4-00c04fd430cx",
+	}
+	for _, s := range invalids {
+		if _, err = ParseUUID(s); err != errInvalidUUID {
+			t.Errorf("expected error %q for %q, got %v", errInvalidUUID, s, err)
+		}
+	}
+}
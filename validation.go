@@ -0,0 +1,370 @@
+// Copyright 2016 The Gem Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file.
+
+package gem
+
+import (
+	"encoding/xml"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// FieldError describes a failed validation rule of struct field.
+type FieldError struct {
+	Field   string `json:"field" xml:"field,attr"`
+	Rule    string `json:"rule" xml:"rule,attr"`
+	Param   string `json:"param,omitempty" xml:"param,attr,omitempty"`
+	Message string `json:"message" xml:",chardata"`
+}
+
+func (e *FieldError) Error() string {
+	return e.Message
+}
+
+// ValidationErrors contains all of the failed validation rules,
+// it can be rendered via Context.JSON and Context.XML directly,
+// such as:
+//
+//	if errs, ok := err.(gem.ValidationErrors); ok {
+//		ctx.JSON(http.StatusUnprocessableEntity, errs)
+//	}
+type ValidationErrors []*FieldError
+
+func (errs ValidationErrors) Error() string {
+	msgs := make([]string, len(errs))
+	for i, err := range errs {
+		msgs[i] = err.Message
+	}
+
+	return strings.Join(msgs, "; ")
+}
+
+// MarshalXML implements the xml.Marshaler interface, the errors
+// are enclosed in the "errors" element.
+func (errs ValidationErrors) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	start = xml.StartElement{Name: xml.Name{Local: "errors"}}
+	if err := e.EncodeToken(start); err != nil {
+		return err
+	}
+
+	for _, err := range errs {
+		if err := e.EncodeElement(err, xml.StartElement{Name: xml.Name{Local: "error"}}); err != nil {
+			return err
+		}
+	}
+
+	return e.EncodeToken(start.End())
+}
+
+// Validate validates the struct pointed to by v via the "validate" tag,
+// the slices and nested structs would be validated recursively.
+//
+// The rules are separated by comma:
+//
+//	required    - the value must not be zero value
+//	omitempty   - the other rules are skipped if the value is zero value
+//	min=N       - the minimum value of number, or the minimum length of
+//	              string, slice, map and array
+//	max=N       - the maximum value or length
+//	len=N       - the exact value or length
+//	email       - the string must be a valid email address
+//	oneof=a b c - the value must be one of the space-separated values
+//	regexp=RE   - the string must match the regular expression, it must
+//	              be the last rule since RE may contain comma
+//
+// The field name of FieldError is the name of "json" tag, or the name of
+// struct field if the tag is absent.
+//
+// If the validation failed, a ValidationErrors would be returned, the
+// other non-nil errors indicate that the rules are invalid.
+func Validate(v interface{}) error {
+	var errs ValidationErrors
+	if err := validateValue(reflect.ValueOf(v), "", &errs); err != nil {
+		return err
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+
+	return nil
+}
+
+func validateValue(v reflect.Value, prefix string, errs *ValidationErrors) error {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+
+	switch v.Kind() {
+	case reflect.Struct:
+		return validateStruct(v, prefix, errs)
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			if err := validateValue(v.Index(i), prefix+"["+strconv.Itoa(i)+"]", errs); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func validateStruct(v reflect.Value, prefix string, errs *ValidationErrors) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" && !field.Anonymous {
+			continue
+		}
+
+		fv := v.Field(i)
+		name := prefix
+		if !field.Anonymous {
+			if name != "" {
+				name += "."
+			}
+			name += validationFieldName(field)
+		}
+
+		tag := field.Tag.Get("validate")
+		if tag == "-" {
+			continue
+		}
+		if tag != "" {
+			ok, err := validateField(fv, name, tag, errs)
+			if err != nil {
+				return fmt.Errorf("invalid validation rules of field %q: %s", field.Name, err)
+			}
+			if !ok {
+				// skip nested validation if the field itself is invalid.
+				continue
+			}
+		}
+
+		if err := validateValue(fv, name, errs); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func validationFieldName(field reflect.StructField) string {
+	name := field.Tag.Get("json")
+	if idx := strings.IndexByte(name, ','); idx >= 0 {
+		name = name[:idx]
+	}
+	if name == "" || name == "-" {
+		return field.Name
+	}
+
+	return name
+}
+
+// validateField validates v by the rules of tag, it reports whether all
+// of the rules are satisfied.
+func validateField(v reflect.Value, name, tag string, errs *ValidationErrors) (bool, error) {
+	zero := isZeroValue(v)
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			break
+		}
+		v = v.Elem()
+	}
+
+	var rules []string
+	if idx := strings.Index(tag, "regexp="); idx >= 0 {
+		rules = append(strings.Split(strings.TrimSuffix(tag[:idx], ","), ","), tag[idx:])
+	} else {
+		rules = strings.Split(tag, ",")
+	}
+
+	if zero {
+		for _, rule := range rules {
+			if strings.TrimSpace(rule) == "omitempty" {
+				return true, nil
+			}
+		}
+	}
+
+	for _, rule := range rules {
+		rule = strings.TrimSpace(rule)
+		if rule == "" || rule == "omitempty" {
+			continue
+		}
+
+		param := ""
+		if idx := strings.IndexByte(rule, '='); idx >= 0 {
+			rule, param = rule[:idx], rule[idx+1:]
+		}
+
+		// the other rules are meaningless for nil pointer.
+		if v.Kind() == reflect.Ptr && rule != "required" {
+			continue
+		}
+
+		msg, err := checkRule(v, zero, name, rule, param)
+		if err != nil {
+			return false, err
+		}
+		if msg != "" {
+			*errs = append(*errs, &FieldError{Field: name, Rule: rule, Param: param, Message: msg})
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+func isZeroValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface, reflect.Slice, reflect.Map, reflect.Chan, reflect.Func:
+		if v.IsNil() {
+			return true
+		}
+		if v.Kind() == reflect.Slice || v.Kind() == reflect.Map {
+			return v.Len() == 0
+		}
+		return false
+	}
+
+	return reflect.DeepEqual(v.Interface(), reflect.Zero(v.Type()).Interface())
+}
+
+var emailRegexp = regexp.MustCompile(`^[a-zA-Z0-9.!#$%&'*+/=?^_{|}~-]+@[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?(?:\.[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?)*$`)
+
+// checkRule returns a non-empty message if v does not satisfy the rule.
+func checkRule(v reflect.Value, zero bool, name, rule, param string) (string, error) {
+	switch rule {
+	case "required":
+		if zero {
+			return name + " is required", nil
+		}
+	case "min", "max", "len":
+		return checkRange(v, name, rule, param)
+	case "email":
+		if v.Kind() != reflect.String {
+			return "", fmt.Errorf("rule %q is not applicable to %s", rule, v.Type())
+		}
+		if !emailRegexp.MatchString(v.String()) {
+			return name + " must be a valid email address", nil
+		}
+	case "oneof":
+		value := fmt.Sprint(v.Interface())
+		for _, option := range strings.Fields(param) {
+			if value == option {
+				return "", nil
+			}
+		}
+		return fmt.Sprintf("%s must be one of [%s]", name, param), nil
+	case "regexp":
+		if v.Kind() != reflect.String {
+			return "", fmt.Errorf("rule %q is not applicable to %s", rule, v.Type())
+		}
+		re, err := compileRegexp(param)
+		if err != nil {
+			return "", err
+		}
+		if !re.MatchString(v.String()) {
+			return name + " has an invalid format", nil
+		}
+	default:
+		return "", fmt.Errorf("unknown rule %q", rule)
+	}
+
+	return "", nil
+}
+
+func checkRange(v reflect.Value, name, rule, param string) (string, error) {
+	var less, greater bool
+	var unit string
+
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(param, 10, 64)
+		if err != nil {
+			return "", err
+		}
+		less, greater = v.Int() < n, v.Int() > n
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		n, err := strconv.ParseUint(param, 10, 64)
+		if err != nil {
+			return "", err
+		}
+		less, greater = v.Uint() < n, v.Uint() > n
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(param, 64)
+		if err != nil {
+			return "", err
+		}
+		less, greater = v.Float() < n, v.Float() > n
+	case reflect.String, reflect.Slice, reflect.Map, reflect.Array:
+		n, err := strconv.Atoi(param)
+		if err != nil {
+			return "", err
+		}
+		length := v.Len()
+		unit = " items"
+		if v.Kind() == reflect.String {
+			length = utf8.RuneCountInString(v.String())
+			unit = " characters"
+		}
+		less, greater = length < n, length > n
+	default:
+		return "", fmt.Errorf("rule %q is not applicable to %s", rule, v.Type())
+	}
+
+	switch {
+	case rule == "min" && less:
+		if unit != "" {
+			return fmt.Sprintf("%s must contain at least %s%s", name, param, unit), nil
+		}
+		return fmt.Sprintf("%s must be %s or greater", name, param), nil
+	case rule == "max" && greater:
+		if unit != "" {
+			return fmt.Sprintf("%s must contain at most %s%s", name, param, unit), nil
+		}
+		return fmt.Sprintf("%s must be %s or less", name, param), nil
+	case rule == "len" && (less || greater):
+		if unit != "" {
+			return fmt.Sprintf("%s must contain exactly %s%s", name, param, unit), nil
+		}
+		return fmt.Sprintf("%s must be equal to %s", name, param), nil
+	}
+
+	return "", nil
+}
+
+var regexps = struct {
+	sync.RWMutex
+	m map[string]*regexp.Regexp
+}{m: make(map[string]*regexp.Regexp)}
+
+func compileRegexp(expr string) (*regexp.Regexp, error) {
+	regexps.RLock()
+	re, ok := regexps.m[expr]
+	regexps.RUnlock()
+	if ok {
+		return re, nil
+	}
+
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, err
+	}
+
+	regexps.Lock()
+	regexps.m[expr] = re
+	regexps.Unlock()
+
+	return re, nil
+}
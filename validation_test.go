@@ -0,0 +1,169 @@
+// Copyright 2016 The Gem Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file.
+
+package gem
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+type testValidationItem struct {
+	Name  string `json:"name" validate:"required"`
+	Count int    `json:"count" validate:"min=1,max=10"`
+}
+
+type testValidationOrder struct {
+	Email    string               `json:"email" validate:"required,email"`
+	Nickname string               `json:"nickname" validate:"omitempty,min=3,max=5"`
+	Code     string               `json:"code" validate:"len=4"`
+	Status   string               `json:"status" validate:"oneof=new paid"`
+	Zip      string               `json:"zip" validate:"regexp=^\\d{3,5}$"`
+	Note     *string              `json:"note" validate:"min=2"`
+	Items    []testValidationItem `json:"items" validate:"required,max=2"`
+	Address  struct {
+		City string `validate:"required"`
+	} `json:"address"`
+	Skipped string `validate:"-"`
+}
+
+func TestValidate(t *testing.T) {
+	order := testValidationOrder{
+		Email:  "foo@example.com",
+		Code:   "abcd",
+		Status: "new",
+		Zip:    "12345",
+		Items:  []testValidationItem{{Name: "foo", Count: 1}},
+	}
+	order.Address.City = "bar"
+	if err := Validate(&order); err != nil {
+		t.Fatalf("expected nil error, got %s", err)
+	}
+
+	note := "x"
+	order = testValidationOrder{
+		Email:    "foo",
+		Nickname: "ab",
+		Code:     "abc",
+		Status:   "canceled",
+		Zip:      "1,2",
+		Note:     &note,
+		Items:    []testValidationItem{{Count: 1}, {Name: "bar", Count: 11}},
+	}
+	err := Validate(&order)
+	errs, ok := err.(ValidationErrors)
+	if !ok {
+		t.Fatalf("expected ValidationErrors, got %v", err)
+	}
+
+	want := []struct {
+		field, rule string
+	}{
+		{"email", "email"},
+		{"nickname", "min"},
+		{"code", "len"},
+		{"status", "oneof"},
+		{"zip", "regexp"},
+		{"note", "min"},
+		{"items[0].name", "required"},
+		{"items[1].count", "max"},
+		{"address.City", "required"},
+	}
+	if len(errs) != len(want) {
+		t.Fatalf("expected %d errors, got %d: %s", len(want), len(errs), errs)
+	}
+	for i, err := range errs {
+		if err.Field != want[i].field || err.Rule != want[i].rule {
+			t.Errorf("expected error of field %q and rule %q, got %q and %q", want[i].field, want[i].rule, err.Field, err.Rule)
+		}
+	}
+
+	// skip the nested validation if the field itself is invalid.
+	order.Items = make([]testValidationItem, 3)
+	errs = Validate(order).(ValidationErrors)
+	if errs[6].Field != "items" || errs[6].Message != "items must contain at most 2 items" {
+		t.Errorf("unexpected error %+v", errs[6])
+	}
+}
+
+func TestValidateInvalidRule(t *testing.T) {
+	tests := []interface{}{
+		&struct {
+			Name string `validate:"foo"`
+		}{},
+		&struct {
+			Age int `validate:"min=abc"`
+		}{},
+		&struct {
+			Age int `validate:"email"`
+		}{},
+		&struct {
+			Name string `validate:"regexp=["`
+		}{},
+	}
+	for _, test := range tests {
+		err := Validate(test)
+		if _, ok := err.(ValidationErrors); err == nil || ok {
+			t.Errorf("expected invalid rule error for %+v, got %v", test, err)
+		}
+	}
+
+	// non-struct values are ignored.
+	if err := Validate("foo"); err != nil {
+		t.Errorf("expected nil error, got %s", err)
+	}
+}
+
+func TestValidationErrors_Marshal(t *testing.T) {
+	errs := ValidationErrors{
+		{Field: "name", Rule: "required", Message: "name is required"},
+		{Field: "age", Rule: "min", Param: "18", Message: "age must be 18 or greater"},
+	}
+
+	if errs.Error() != "name is required; age must be 18 or greater" {
+		t.Errorf("unexpected error message %q", errs.Error())
+	}
+
+	data, err := json.Marshal(errs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `[{"field":"name","rule":"required","message":"name is required"},{"field":"age","rule":"min","param":"18","message":"age must be 18 or greater"}]`
+	if string(data) != want {
+		t.Errorf("expected json %s, got %s", want, data)
+	}
+
+	data, err = xml.Marshal(errs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = `<errors><error field="name" rule="required">name is required</error><error field="age" rule="min" param="18">age must be 18 or greater</error></errors>`
+	if string(data) != want {
+		t.Errorf("expected xml %s, got %s", want, data)
+	}
+}
+
+func TestContext_BindValidate(t *testing.T) {
+	var item testValidationItem
+	ctx := &Context{Request: newBindingRequest(MethodPost, "/", MIMEJSON, `{"name":"","count":1}`)}
+	err := ctx.Bind(&item)
+	want := ValidationErrors{{Field: "name", Rule: "required", Message: "name is required"}}
+	if !reflect.DeepEqual(err, want) {
+		t.Errorf("expected error %v, got %v", want, err)
+	}
+
+	ctx = &Context{Request: newBindingRequest(MethodPost, "/", MIMEJSON, `{"name":"foo","count":1}`)}
+	if err = ctx.Bind(&item); err != nil {
+		t.Errorf("expected nil error, got %s", err)
+	}
+
+	req, _ := http.NewRequest(MethodGet, "/?count=0", nil)
+	ctx = &Context{Request: req}
+	if err = ctx.BindQuery(&item); err != nil {
+		t.Errorf("BindQuery should not validate the data, got %s", err)
+	}
+}
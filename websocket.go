@@ -0,0 +1,611 @@
+// Copyright 2016 The Gem Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file.
+
+package gem
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// The message types of WebSocket, see RFC 6455, section 11.8.
+const (
+	TextMessage   = 1
+	BinaryMessage = 2
+	CloseMessage  = 8
+	PingMessage   = 9
+	PongMessage   = 10
+
+	continuationFrame = 0
+)
+
+// The close codes of WebSocket, see RFC 6455, section 7.4.1.
+const (
+	CloseNormalClosure           = 1000
+	CloseGoingAway               = 1001
+	CloseProtocolError           = 1002
+	CloseUnsupportedData         = 1003
+	CloseNoStatusReceived        = 1005
+	CloseAbnormalClosure         = 1006
+	CloseInvalidFramePayloadData = 1007
+	ClosePolicyViolation         = 1008
+	CloseMessageTooBig           = 1009
+	CloseMandatoryExtension      = 1010
+	CloseInternalServerErr       = 1011
+)
+
+// websocketGUID is used to compute the Sec-WebSocket-Accept header.
+const websocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// defaultWebSocketReadLimit is the default maximum size of message.
+const defaultWebSocketReadLimit = 1 << 20
+
+// maxControlPayload is the maximum payload size of control frames.
+const maxControlPayload = 125
+
+var (
+	errWebSocketWritten       = errors.New("the response has been written before upgrading")
+	errWebSocketCloseSent     = errors.New("the close frame has been sent")
+	errWebSocketInvalidType   = errors.New("invalid WebSocket message type")
+	errWebSocketReasonTooLong = errors.New("the close reason is too long")
+)
+
+// CloseError is returned by WebSocketConn.ReadMessage once the close
+// frame has been received, or the connection is closed abnormally.
+type CloseError struct {
+	Code int
+	Text string
+}
+
+func (e *CloseError) Error() string {
+	return "websocket closed with code " + strconv.Itoa(e.Code) + ": " + e.Text
+}
+
+// websocketProtocolError is a violation of the protocol, the connection
+// is closed with the code.
+type websocketProtocolError struct {
+	code int
+	msg  string
+}
+
+func (e *websocketProtocolError) Error() string {
+	return e.msg
+}
+
+// WebSocketUpgrader upgrades the HTTP connections to the WebSocket
+// connections, see Context.Upgrade.
+type WebSocketUpgrader struct {
+	// CheckOrigin reports whether the Origin header is acceptable, the
+	// requests without Origin header, or from the same host are accepted
+	// if nil.
+	CheckOrigin func(r *http.Request) bool
+
+	// Subprotocols are the supported subprotocols in order of preference.
+	Subprotocols []string
+
+	// ReadLimit is the maximum size of message in bytes, defaults
+	// to 1MB, see WebSocketConn.SetReadLimit.
+	ReadLimit int64
+}
+
+var defaultWebSocketUpgrader = &WebSocketUpgrader{}
+
+// Upgrade upgrades the connection of ctx to WebSocket via the
+// Router.WebSocketUpgrader, or the default upgrader if it is not set.
+//
+// See WebSocketUpgrader.Upgrade.
+func (ctx *Context) Upgrade() (*WebSocketConn, error) {
+	upgrader := defaultWebSocketUpgrader
+	if ctx.router != nil && ctx.router.WebSocketUpgrader != nil {
+		upgrader = ctx.router.WebSocketUpgrader
+	}
+
+	return upgrader.Upgrade(ctx)
+}
+
+// Upgrade performs the opening handshake of RFC 6455 and hijacks the
+// connection, the returned connection should be closed by the caller.
+//
+// If the handshake failed, the request is answered with an error
+// response, and a *HTTPError would be returned.
+func (u *WebSocketUpgrader) Upgrade(ctx *Context) (*WebSocketConn, error) {
+	r := ctx.Request
+
+	if r.Method != MethodGet {
+		return nil, u.fail(ctx, http.StatusMethodNotAllowed, "the method is not GET")
+	}
+	if !headerContainsToken(r.Header, "Connection", "upgrade") {
+		return nil, u.fail(ctx, http.StatusBadRequest, "the Connection header does not contain upgrade")
+	}
+	if !headerContainsToken(r.Header, "Upgrade", "websocket") {
+		return nil, u.fail(ctx, http.StatusBadRequest, "the Upgrade header does not contain websocket")
+	}
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		ctx.Response.Header().Set("Sec-WebSocket-Version", "13")
+		return nil, u.fail(ctx, http.StatusUpgradeRequired, "unsupported WebSocket version")
+	}
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if decoded, err := base64.StdEncoding.DecodeString(key); err != nil || len(decoded) != 16 {
+		return nil, u.fail(ctx, http.StatusBadRequest, "invalid Sec-WebSocket-Key")
+	}
+
+	checkOrigin := u.CheckOrigin
+	if checkOrigin == nil {
+		checkOrigin = checkSameOrigin
+	}
+	if !checkOrigin(r) {
+		return nil, u.fail(ctx, http.StatusForbidden, "the origin is not allowed")
+	}
+
+	rw := ctx.ResponseWriter()
+	if rw.Written() {
+		return nil, errWebSocketWritten
+	}
+
+	subprotocol := u.selectSubprotocol(r)
+
+	netConn, brw, err := rw.Hijack()
+	if err != nil {
+		return nil, u.fail(ctx, http.StatusInternalServerError, err.Error())
+	}
+	rw.status = http.StatusSwitchingProtocols
+
+	resp := "HTTP/1.1 101 Switching Protocols\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + computeAcceptKey(key) + "\r\n"
+	if subprotocol != "" {
+		resp += "Sec-WebSocket-Protocol: " + subprotocol + "\r\n"
+	}
+	resp += "\r\n"
+
+	// clear the deadlines that were set by the server.
+	netConn.SetDeadline(time.Time{})
+	if _, err = brw.WriteString(resp); err == nil {
+		err = brw.Flush()
+	}
+	if err != nil {
+		netConn.Close()
+		return nil, err
+	}
+
+	conn := &WebSocketConn{
+		conn:        netConn,
+		br:          brw.Reader,
+		subprotocol: subprotocol,
+	}
+	conn.SetReadLimit(u.ReadLimit)
+
+	return conn, nil
+}
+
+func (u *WebSocketUpgrader) fail(ctx *Context, status int, message string) error {
+	if !ctx.Written() {
+		http.Error(ctx.Response, message, status)
+	}
+
+	return &HTTPError{Status: status, Message: message}
+}
+
+func (u *WebSocketUpgrader) selectSubprotocol(r *http.Request) string {
+	offers := headerTokens(r.Header, "Sec-WebSocket-Protocol")
+	for _, subprotocol := range u.Subprotocols {
+		for _, offer := range offers {
+			if offer == subprotocol {
+				return subprotocol
+			}
+		}
+	}
+
+	return ""
+}
+
+// checkSameOrigin accepts the request without Origin header, or the
+// host of Origin header is equal to the Host header.
+func checkSameOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+
+	return strings.EqualFold(u.Host, r.Host)
+}
+
+func headerTokens(header http.Header, name string) []string {
+	var tokens []string
+	for _, value := range header[http.CanonicalHeaderKey(name)] {
+		for _, token := range strings.Split(value, ",") {
+			if token = strings.TrimSpace(token); token != "" {
+				tokens = append(tokens, token)
+			}
+		}
+	}
+
+	return tokens
+}
+
+func headerContainsToken(header http.Header, name, token string) bool {
+	for _, t := range headerTokens(header, name) {
+		if strings.EqualFold(t, token) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func computeAcceptKey(key string) string {
+	h := sha1.New()
+	io.WriteString(h, key+websocketGUID)
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+// WebSocketConn is a WebSocket connection on the server side.
+//
+// The ReadMessage should be called by one goroutine at a time, the
+// write methods are safe to be called concurrently.
+type WebSocketConn struct {
+	conn        net.Conn
+	br          *bufio.Reader
+	subprotocol string
+
+	readLimit   int64
+	readErr     error
+	pongHandler func(data string)
+
+	writeMu   sync.Mutex
+	closeSent bool
+}
+
+// Subprotocol returns the negotiated subprotocol.
+func (c *WebSocketConn) Subprotocol() string {
+	return c.subprotocol
+}
+
+// RemoteAddr returns the network address of the client.
+func (c *WebSocketConn) RemoteAddr() net.Addr {
+	return c.conn.RemoteAddr()
+}
+
+// SetReadLimit sets the maximum size of message in bytes, non-positive
+// limit means the default limit 1MB, since the payload is buffered in
+// memory. The connection is closed with CloseMessageTooBig if the limit
+// is exceeded.
+func (c *WebSocketConn) SetReadLimit(limit int64) {
+	if limit <= 0 {
+		limit = defaultWebSocketReadLimit
+	}
+	c.readLimit = limit
+}
+
+// SetReadDeadline sets the deadline of reading, see net.Conn.
+func (c *WebSocketConn) SetReadDeadline(t time.Time) error {
+	return c.conn.SetReadDeadline(t)
+}
+
+// SetWriteDeadline sets the deadline of writing, see net.Conn.
+func (c *WebSocketConn) SetWriteDeadline(t time.Time) error {
+	return c.conn.SetWriteDeadline(t)
+}
+
+// SetPongHandler sets the handler of pong messages, which is invoked
+// by ReadMessage, it is useful to extend the read deadline:
+//
+//	conn.SetPongHandler(func(string) {
+//		conn.SetReadDeadline(time.Now().Add(time.Minute))
+//	})
+func (c *WebSocketConn) SetPongHandler(h func(data string)) {
+	c.pongHandler = h
+}
+
+// ReadMessage reads the next text or binary message, the fragmented
+// messages are reassembled. The ping messages are replied with pong
+// automatically.
+//
+// Once the close frame has been received, it is replied, and a
+// *CloseError would be returned, the subsequent calls return the same
+// error.
+func (c *WebSocketConn) ReadMessage() (messageType int, p []byte, err error) {
+	if c.readErr != nil {
+		return 0, nil, c.readErr
+	}
+
+	for {
+		fin, opcode, payload, err := c.readFrame(int64(len(p)))
+		if err != nil {
+			return 0, nil, c.readFail(err)
+		}
+
+		switch opcode {
+		case PingMessage:
+			if err := c.writeFrame(PongMessage, payload); err != nil && err != errWebSocketCloseSent {
+				return 0, nil, err
+			}
+			continue
+		case PongMessage:
+			if c.pongHandler != nil {
+				c.pongHandler(string(payload))
+			}
+			continue
+		case CloseMessage:
+			return 0, nil, c.readFail(c.handleClose(payload))
+		case continuationFrame:
+			if messageType == 0 {
+				return 0, nil, c.readFail(&websocketProtocolError{CloseProtocolError, "unexpected continuation frame"})
+			}
+		default:
+			if messageType != 0 {
+				return 0, nil, c.readFail(&websocketProtocolError{CloseProtocolError, "expected continuation frame"})
+			}
+			messageType = opcode
+		}
+
+		p = append(p, payload...)
+		if !fin {
+			continue
+		}
+
+		if messageType == TextMessage && !utf8.Valid(p) {
+			return 0, nil, c.readFail(&websocketProtocolError{CloseInvalidFramePayloadData, "invalid UTF-8 text message"})
+		}
+		return messageType, p, nil
+	}
+}
+
+// readFrame reads a frame, size is the size of message that has been read.
+func (c *WebSocketConn) readFrame(size int64) (fin bool, opcode int, payload []byte, err error) {
+	var header [8]byte
+	if _, err = io.ReadFull(c.br, header[:2]); err != nil {
+		return
+	}
+
+	fin = header[0]&0x80 != 0
+	opcode = int(header[0] & 0x0f)
+	masked := header[1]&0x80 != 0
+	length := int64(header[1] & 0x7f)
+
+	if header[0]&0x70 != 0 {
+		err = &websocketProtocolError{CloseProtocolError, "reserved bits are set"}
+		return
+	}
+	switch opcode {
+	case continuationFrame, TextMessage, BinaryMessage:
+	case CloseMessage, PingMessage, PongMessage:
+		if !fin || length > maxControlPayload {
+			err = &websocketProtocolError{CloseProtocolError, "invalid control frame"}
+			return
+		}
+	default:
+		err = &websocketProtocolError{CloseProtocolError, "unknown opcode " + strconv.Itoa(opcode)}
+		return
+	}
+	if !masked {
+		err = &websocketProtocolError{CloseProtocolError, "the frame of client is not masked"}
+		return
+	}
+
+	switch length {
+	case 126:
+		if _, err = io.ReadFull(c.br, header[:2]); err != nil {
+			return
+		}
+		length = int64(binary.BigEndian.Uint16(header[:2]))
+	case 127:
+		if _, err = io.ReadFull(c.br, header[:8]); err != nil {
+			return
+		}
+		if header[0]&0x80 != 0 {
+			err = &websocketProtocolError{CloseProtocolError, "invalid payload length"}
+			return
+		}
+		length = int64(binary.BigEndian.Uint64(header[:8]))
+	}
+
+	if opcode < CloseMessage && length > c.readLimit-size {
+		err = &websocketProtocolError{CloseMessageTooBig, "the message exceeds the read limit"}
+		return
+	}
+
+	var mask [4]byte
+	if _, err = io.ReadFull(c.br, mask[:]); err != nil {
+		return
+	}
+	payload = make([]byte, length)
+	if _, err = io.ReadFull(c.br, payload); err != nil {
+		return
+	}
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+
+	return
+}
+
+// handleClose replies the close frame, and returns the CloseError.
+func (c *WebSocketConn) handleClose(payload []byte) error {
+	closeErr := &CloseError{Code: CloseNoStatusReceived}
+	if len(payload) > 0 {
+		if len(payload) < 2 {
+			return &websocketProtocolError{CloseProtocolError, "invalid close frame"}
+		}
+		closeErr.Code = int(binary.BigEndian.Uint16(payload))
+		closeErr.Text = string(payload[2:])
+		if !isValidCloseCode(closeErr.Code) {
+			return &websocketProtocolError{CloseProtocolError, "invalid close code " + strconv.Itoa(closeErr.Code)}
+		}
+		if !utf8.ValidString(closeErr.Text) {
+			return &websocketProtocolError{CloseInvalidFramePayloadData, "invalid UTF-8 close reason"}
+		}
+	}
+
+	var reply []byte
+	if closeErr.Code != CloseNoStatusReceived {
+		reply = formatCloseMessage(closeErr.Code, "")
+	}
+	if err := c.writeFrame(CloseMessage, reply); err != nil && err != errWebSocketCloseSent {
+		return err
+	}
+
+	return closeErr
+}
+
+// readFail records the error of reading, the protocol errors are
+// reported to the client via close frame.
+func (c *WebSocketConn) readFail(err error) error {
+	switch e := err.(type) {
+	case *websocketProtocolError:
+		c.WriteClose(e.code, "")
+	case *CloseError:
+	default:
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			err = &CloseError{Code: CloseAbnormalClosure, Text: err.Error()}
+		}
+	}
+
+	c.readErr = err
+	return err
+}
+
+func isValidCloseCode(code int) bool {
+	switch {
+	case code >= 1000 && code <= 1003, code >= 1007 && code <= 1014:
+		return true
+	case code >= 3000 && code <= 4999:
+		return true
+	}
+
+	return false
+}
+
+func formatCloseMessage(code int, reason string) []byte {
+	p := make([]byte, 2+len(reason))
+	binary.BigEndian.PutUint16(p, uint16(code))
+	copy(p[2:], reason)
+	return p
+}
+
+// WriteMessage writes a text or binary message in a single frame.
+func (c *WebSocketConn) WriteMessage(messageType int, data []byte) error {
+	if messageType != TextMessage && messageType != BinaryMessage {
+		return errWebSocketInvalidType
+	}
+
+	return c.writeFrame(messageType, data)
+}
+
+// WritePing writes a ping message, the data must not be longer
+// than 125 bytes.
+func (c *WebSocketConn) WritePing(data []byte) error {
+	if len(data) > maxControlPayload {
+		return fmt.Errorf("the ping data exceeds %d bytes", maxControlPayload)
+	}
+
+	return c.writeFrame(PingMessage, data)
+}
+
+// WriteClose writes a close message with the given code and reason,
+// the reason must not be longer than 123 bytes. The connection should
+// be closed after the client replied, or the timeout elapsed.
+func (c *WebSocketConn) WriteClose(code int, reason string) error {
+	if len(reason) > maxControlPayload-2 {
+		return errWebSocketReasonTooLong
+	}
+
+	return c.writeFrame(CloseMessage, formatCloseMessage(code, reason))
+}
+
+func (c *WebSocketConn) writeFrame(opcode int, payload []byte) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	if c.closeSent {
+		return errWebSocketCloseSent
+	}
+	if opcode == CloseMessage {
+		c.closeSent = true
+	}
+
+	length := len(payload)
+	frame := make([]byte, 0, 10+length)
+	frame = append(frame, 0x80|byte(opcode))
+	switch {
+	case length <= 125:
+		frame = append(frame, byte(length))
+	case length <= 0xffff:
+		frame = append(frame, 126, byte(length>>8), byte(length))
+	default:
+		frame = append(frame, 127)
+		var n [8]byte
+		binary.BigEndian.PutUint64(n[:], uint64(length))
+		frame = append(frame, n[:]...)
+	}
+	frame = append(frame, payload...)
+
+	_, err := c.conn.Write(frame)
+	return err
+}
+
+// Close closes the underlying connection without sending the close
+// message, see WriteClose.
+func (c *WebSocketConn) Close() error {
+	return c.conn.Close()
+}
+
+// WebSocket registers a WebSocket handler with the given path, the
+// connection is upgraded via Context.Upgrade, and closed after the
+// handler returned:
+//
+//	router.WebSocket("/echo", func(ctx *gem.Context, conn *gem.WebSocketConn) {
+//		for {
+//			messageType, p, err := conn.ReadMessage()
+//			if err != nil {
+//				return
+//			}
+//			if err = conn.WriteMessage(messageType, p); err != nil {
+//				return
+//			}
+//		}
+//	})
+func (r *Router) WebSocket(path string, handler func(*Context, *WebSocketConn), opts ...*HandlerOption) {
+	r.GET(path, websocketHandle(handler), opts...)
+}
+
+// WebSocket registers a WebSocket handler with the given path, the
+// path would be prefixed with the group's prefix.
+//
+// See Router.WebSocket.
+func (g *Group) WebSocket(path string, handler func(*Context, *WebSocketConn), opts ...*HandlerOption) {
+	g.GET(path, websocketHandle(handler), opts...)
+}
+
+func websocketHandle(handler func(*Context, *WebSocketConn)) HandlerFunc {
+	return E(func(ctx *Context) error {
+		conn, err := ctx.Upgrade()
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+
+		handler(ctx, conn)
+		return nil
+	})
+}
@@ -0,0 +1,396 @@
+// Copyright 2016 The Gem Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file.
+
+package gem
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testWebSocketKey = "dGhlIHNhbXBsZSBub25jZQ=="
+
+// newWebSocketServer serves the router, and returns the address.
+func newWebSocketServer(router *Router) (addr string, closeFunc func()) {
+	srv := New("")
+	srv.init(router.Handler())
+	ts := httptest.NewServer(srv.Server.Handler)
+	return ts.Listener.Addr().String(), ts.Close
+}
+
+// dialWebSocket performs the opening handshake as a client.
+func dialWebSocket(t *testing.T, addr, path string, header http.Header) (net.Conn, *bufio.Reader, *http.Response) {
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	req, _ := http.NewRequest(MethodGet, "http://"+addr+path, nil)
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	req.Header.Set("Sec-WebSocket-Key", testWebSocketKey)
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	if err = req.Write(conn); err != nil {
+		t.Fatal(err)
+	}
+
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return conn, br, resp
+}
+
+// writeClientFrame writes a masked frame.
+func writeClientFrame(t *testing.T, conn net.Conn, fin bool, opcode int, payload []byte) {
+	b0 := byte(opcode)
+	if fin {
+		b0 |= 0x80
+	}
+	frame := []byte{b0}
+	switch {
+	case len(payload) <= 125:
+		frame = append(frame, 0x80|byte(len(payload)))
+	default:
+		frame = append(frame, 0x80|126, byte(len(payload)>>8), byte(len(payload)))
+	}
+	mask := []byte{1, 2, 3, 4}
+	frame = append(frame, mask...)
+	for i, b := range payload {
+		frame = append(frame, b^mask[i%4])
+	}
+
+	if _, err := conn.Write(frame); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// readServerFrame reads an unmasked frame.
+func readServerFrame(t *testing.T, br *bufio.Reader) (opcode int, payload []byte) {
+	var header [2]byte
+	if _, err := io.ReadFull(br, header[:]); err != nil {
+		t.Fatal(err)
+	}
+	if header[0]&0x80 == 0 || header[1]&0x80 != 0 {
+		t.Fatalf("expected a final and unmasked frame, got header %x", header)
+	}
+
+	opcode, length := int(header[0]&0x0f), int(header[1])
+	if length == 126 {
+		if _, err := io.ReadFull(br, header[:]); err != nil {
+			t.Fatal(err)
+		}
+		length = int(binary.BigEndian.Uint16(header[:]))
+	}
+	payload = make([]byte, length)
+	if _, err := io.ReadFull(br, payload); err != nil {
+		t.Fatal(err)
+	}
+	return opcode, payload
+}
+
+func TestComputeAcceptKey(t *testing.T) {
+	// the example of RFC 6455, section 1.3.
+	if key := computeAcceptKey(testWebSocketKey); key != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Errorf("unexpected accept key %q", key)
+	}
+}
+
+func TestRouterWebSocket(t *testing.T) {
+	readErr := make(chan error, 1)
+	router := NewRouter()
+	router.WebSocketUpgrader = &WebSocketUpgrader{Subprotocols: []string{"chat", "superchat"}}
+	router.WebSocket("/echo", func(ctx *Context, conn *WebSocketConn) {
+		for {
+			messageType, p, err := conn.ReadMessage()
+			if err != nil {
+				readErr <- err
+				return
+			}
+			if err = conn.WriteMessage(messageType, p); err != nil {
+				readErr <- err
+				return
+			}
+		}
+	})
+
+	addr, closeFunc := newWebSocketServer(router)
+	defer closeFunc()
+
+	conn, br, resp := dialWebSocket(t, addr, "/echo", http.Header{
+		"Origin":                 {"http://" + addr},
+		"Sec-Websocket-Protocol": {"superchat, chat"},
+	})
+	defer conn.Close()
+
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("expected status %d, got %d", http.StatusSwitchingProtocols, resp.StatusCode)
+	}
+	if accept := resp.Header.Get("Sec-WebSocket-Accept"); accept != computeAcceptKey(testWebSocketKey) {
+		t.Errorf("unexpected accept key %q", accept)
+	}
+	if protocol := resp.Header.Get("Sec-WebSocket-Protocol"); protocol != "chat" {
+		t.Errorf("expected subprotocol %q, got %q", "chat", protocol)
+	}
+
+	writeClientFrame(t, conn, true, TextMessage, []byte("hello"))
+	if opcode, payload := readServerFrame(t, br); opcode != TextMessage || string(payload) != "hello" {
+		t.Errorf("expected text message %q, got %d %q", "hello", opcode, payload)
+	}
+
+	// fragmented message with interleaved ping.
+	long := bytes.Repeat([]byte("a"), 300)
+	writeClientFrame(t, conn, false, BinaryMessage, long)
+	writeClientFrame(t, conn, true, PingMessage, []byte("ping"))
+	writeClientFrame(t, conn, false, continuationFrame, []byte("b"))
+	writeClientFrame(t, conn, true, continuationFrame, []byte("c"))
+	if opcode, payload := readServerFrame(t, br); opcode != PongMessage || string(payload) != "ping" {
+		t.Errorf("expected pong %q, got %d %q", "ping", opcode, payload)
+	}
+	want := string(long) + "bc"
+	if opcode, payload := readServerFrame(t, br); opcode != BinaryMessage || string(payload) != want {
+		t.Errorf("expected binary message of %d bytes, got %d %d bytes", len(want), opcode, len(payload))
+	}
+
+	writeClientFrame(t, conn, true, CloseMessage, formatCloseMessage(CloseGoingAway, "bye"))
+	if opcode, payload := readServerFrame(t, br); opcode != CloseMessage || binary.BigEndian.Uint16(payload) != CloseGoingAway {
+		t.Errorf("expected close frame with code %d, got %d %v", CloseGoingAway, opcode, payload)
+	}
+
+	select {
+	case err := <-readErr:
+		closeErr, ok := err.(*CloseError)
+		if !ok || closeErr.Code != CloseGoingAway || closeErr.Text != "bye" {
+			t.Errorf("expected close error with code %d, got %v", CloseGoingAway, err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the handler did not receive the close frame")
+	}
+}
+
+func TestWebSocketConnErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		write  func(t *testing.T, conn net.Conn)
+		code   int
+		errMsg string
+	}{
+		{
+			name: "read limit",
+			write: func(t *testing.T, conn net.Conn) {
+				writeClientFrame(t, conn, false, TextMessage, []byte("12345"))
+				writeClientFrame(t, conn, true, continuationFrame, []byte("67890"))
+			},
+			code:   CloseMessageTooBig,
+			errMsg: "the message exceeds the read limit",
+		},
+		{
+			name: "64-bit payload length",
+			write: func(t *testing.T, conn net.Conn) {
+				writeClientFrame(t, conn, false, TextMessage, []byte("12345"))
+				if _, err := conn.Write([]byte{0x80, 0x80 | 127, 0x7f, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}); err != nil {
+					t.Fatal(err)
+				}
+			},
+			code:   CloseMessageTooBig,
+			errMsg: "the message exceeds the read limit",
+		},
+		{
+			name: "unmasked",
+			write: func(t *testing.T, conn net.Conn) {
+				conn.Write([]byte{0x81, 0x02, 'h', 'i'})
+			},
+			code:   CloseProtocolError,
+			errMsg: "the frame of client is not masked",
+		},
+		{
+			name: "invalid UTF-8",
+			write: func(t *testing.T, conn net.Conn) {
+				writeClientFrame(t, conn, true, TextMessage, []byte{0xff, 0xfe})
+			},
+			code:   CloseInvalidFramePayloadData,
+			errMsg: "invalid UTF-8 text message",
+		},
+		{
+			name: "unexpected continuation",
+			write: func(t *testing.T, conn net.Conn) {
+				writeClientFrame(t, conn, true, continuationFrame, []byte("foo"))
+			},
+			code:   CloseProtocolError,
+			errMsg: "unexpected continuation frame",
+		},
+		{
+			name: "invalid close code",
+			write: func(t *testing.T, conn net.Conn) {
+				writeClientFrame(t, conn, true, CloseMessage, formatCloseMessage(CloseAbnormalClosure, ""))
+			},
+			code:   CloseProtocolError,
+			errMsg: "invalid close code 1006",
+		},
+	}
+
+	for _, test := range tests {
+		readErr := make(chan error, 1)
+		router := NewRouter()
+		router.WebSocketUpgrader = &WebSocketUpgrader{ReadLimit: 8}
+		router.WebSocket("/ws", func(ctx *Context, conn *WebSocketConn) {
+			_, _, err := conn.ReadMessage()
+			readErr <- err
+		})
+
+		addr, closeFunc := newWebSocketServer(router)
+		conn, br, _ := dialWebSocket(t, addr, "/ws", nil)
+
+		test.write(t, conn)
+		if opcode, payload := readServerFrame(t, br); opcode != CloseMessage || int(binary.BigEndian.Uint16(payload)) != test.code {
+			t.Errorf("%s: expected close frame with code %d, got %d %v", test.name, test.code, opcode, payload)
+		}
+		if err := <-readErr; err == nil || err.Error() != test.errMsg {
+			t.Errorf("%s: expected error %q, got %v", test.name, test.errMsg, err)
+		}
+
+		conn.Close()
+		closeFunc()
+	}
+}
+
+func TestWebSocketConnSetReadLimit(t *testing.T) {
+	c := &WebSocketConn{}
+	for _, limit := range []int64{0, -1} {
+		c.SetReadLimit(limit)
+		if c.readLimit != defaultWebSocketReadLimit {
+			t.Errorf("expected the default read limit of %d, got %d", limit, c.readLimit)
+		}
+	}
+	c.SetReadLimit(8)
+	if c.readLimit != 8 {
+		t.Errorf("expected read limit 8, got %d", c.readLimit)
+	}
+}
+
+func TestWebSocketConnWrite(t *testing.T) {
+	result := make(chan []error, 1)
+	router := NewRouter()
+	router.WebSocket("/ws", func(ctx *Context, conn *WebSocketConn) {
+		result <- []error{
+			conn.WriteMessage(PingMessage, nil),
+			conn.WritePing([]byte("ping")),
+			conn.WritePing(make([]byte, 126)),
+			conn.WriteClose(CloseNormalClosure, strings.Repeat("a", 124)),
+			conn.WriteClose(CloseNormalClosure, "done"),
+			conn.WriteMessage(TextMessage, []byte("foo")),
+		}
+
+		// the pong is handled while reading.
+		conn.SetPongHandler(func(data string) {
+			result <- []error{nil}
+		})
+		conn.ReadMessage()
+	})
+
+	addr, closeFunc := newWebSocketServer(router)
+	defer closeFunc()
+	conn, br, _ := dialWebSocket(t, addr, "/ws", nil)
+	defer conn.Close()
+
+	errs := <-result
+	if errs[0] != errWebSocketInvalidType {
+		t.Errorf("expected error %q, got %v", errWebSocketInvalidType, errs[0])
+	}
+	if errs[1] != nil || errs[2] == nil {
+		t.Errorf("unexpected ping errors %v, %v", errs[1], errs[2])
+	}
+	if errs[3] != errWebSocketReasonTooLong || errs[4] != nil {
+		t.Errorf("unexpected close errors %v, %v", errs[3], errs[4])
+	}
+	if errs[5] != errWebSocketCloseSent {
+		t.Errorf("expected error %q, got %v", errWebSocketCloseSent, errs[5])
+	}
+
+	if opcode, payload := readServerFrame(t, br); opcode != PingMessage || string(payload) != "ping" {
+		t.Errorf("expected ping %q, got %d %q", "ping", opcode, payload)
+	}
+	if opcode, payload := readServerFrame(t, br); opcode != CloseMessage || string(payload[2:]) != "done" {
+		t.Errorf("expected close frame %q, got %d %q", "done", opcode, payload)
+	}
+
+	writeClientFrame(t, conn, true, PongMessage, []byte("ping"))
+	select {
+	case <-result:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the pong handler was not invoked")
+	}
+}
+
+func TestWebSocketUpgradeErrors(t *testing.T) {
+	tests := []struct {
+		method   string
+		header   map[string]string
+		upgrader *WebSocketUpgrader
+		status   int
+	}{
+		{method: MethodPost, status: http.StatusMethodNotAllowed},
+		{header: map[string]string{"Connection": "keep-alive"}, status: http.StatusBadRequest},
+		{header: map[string]string{"Upgrade": "h2c"}, status: http.StatusBadRequest},
+		{header: map[string]string{"Sec-WebSocket-Version": "8"}, status: http.StatusUpgradeRequired},
+		{header: map[string]string{"Sec-WebSocket-Key": "foo"}, status: http.StatusBadRequest},
+		{header: map[string]string{"Origin": "http://evil.com"}, status: http.StatusForbidden},
+		{
+			header:   map[string]string{"Origin": "http://example.com"},
+			upgrader: &WebSocketUpgrader{CheckOrigin: func(r *http.Request) bool { return false }},
+			status:   http.StatusForbidden,
+		},
+		// the recorder does not support hijacking.
+		{header: map[string]string{"Origin": "http://EXAMPLE.com"}, status: http.StatusInternalServerError},
+		{
+			header:   map[string]string{"Origin": "http://evil.com"},
+			upgrader: &WebSocketUpgrader{CheckOrigin: func(r *http.Request) bool { return true }},
+			status:   http.StatusInternalServerError,
+		},
+	}
+
+	for _, test := range tests {
+		method := test.method
+		if method == "" {
+			method = MethodGet
+		}
+		req := httptest.NewRequest(method, "http://example.com/ws", nil)
+		req.Header.Set("Connection", "keep-alive, Upgrade")
+		req.Header.Set("Upgrade", "WebSocket")
+		req.Header.Set("Sec-WebSocket-Version", "13")
+		req.Header.Set("Sec-WebSocket-Key", testWebSocketKey)
+		for key, value := range test.header {
+			req.Header.Set(key, value)
+		}
+
+		w := httptest.NewRecorder()
+		ctx := newContext(nil, w, req)
+		upgrader := test.upgrader
+		if upgrader == nil {
+			upgrader = &WebSocketUpgrader{}
+		}
+
+		conn, err := upgrader.Upgrade(ctx)
+		if conn != nil {
+			t.Errorf("%s %v: expected nil connection", method, test.header)
+		}
+		httpErr, ok := err.(*HTTPError)
+		if !ok || httpErr.Status != test.status || w.Code != test.status {
+			t.Errorf("%s %v: expected status %d, got %d %v", method, test.header, test.status, w.Code, err)
+		}
+	}
+}